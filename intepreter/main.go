@@ -1,22 +1,60 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/o-richard/intepreter/marble"
 )
 
+type pathList []string
+
+func (p *pathList) String() string { return strings.Join(*p, ",") }
+
+func (p *pathList) Set(value string) error {
+	*p = append(*p, strings.Split(value, ",")...)
+	return nil
+}
+
 func main() {
-	var filepath string
+	if len(os.Args) > 1 && os.Args[1] == "test" {
+		os.Exit(runTests(os.Args[2:]))
+	}
+
+	var filepath, coverProfile, saveState, loadState string
+	var capabilities marble.Capabilities
+	var allowRead, allowWrite pathList
+	var tokens, ast, astJSON, transpile, debug, profile, optimize bool
 	flag.StringVar(&filepath, "filepath", "", "the path of the file to open")
+	flag.Var(&allowRead, "allow-read", "comma separated paths the script may read (repeatable)")
+	flag.Var(&allowWrite, "allow-write", "comma separated paths the script may write (repeatable)")
+	flag.BoolVar(&capabilities.Env, "allow-env", false, "allow the script to read environment variables")
+	flag.BoolVar(&capabilities.Exec, "allow-exec", false, "allow the script to execute commands")
+	flag.BoolVar(&capabilities.Exit, "allow-exit", false, "allow the script to exit the process")
+	flag.BoolVar(&tokens, "tokens", false, "print the token stream instead of running the script")
+	flag.BoolVar(&ast, "ast", false, "print the parsed program as an indented tree instead of running the script")
+	flag.BoolVar(&astJSON, "ast-json", false, "print the parsed program as JSON instead of running the script")
+	flag.BoolVar(&transpile, "transpile", false, "print the program as the source of a Go program instead of running the script")
+	flag.BoolVar(&optimize, "optimize", true, "fold constant expressions and remove dead branches before running the script")
+	flag.BoolVar(&debug, "debug", false, "run the script in the step debugger, reading commands from stdin")
+	flag.BoolVar(&profile, "profile", false, "print function call counts, cumulative times and line counts to stderr after running the script")
+	flag.StringVar(&coverProfile, "coverprofile", "", "write an LCOV coverage profile to the file after running the script")
+	flag.StringVar(&loadState, "load-state", "", "declare the global identifiers saved in the file before running the script")
+	flag.StringVar(&saveState, "save-state", "", "save the global identifiers to the file after running the script")
 	flag.Parse()
 	if filepath == "" {
 		flag.Usage()
 		os.Exit(1)
 	}
+	if debug && (profile || coverProfile != "") {
+		fmt.Println("the debugger cannot be combined with profiling")
+		os.Exit(1)
+	}
 
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -31,6 +69,14 @@ func main() {
 		return
 	}
 
+	if tokens {
+		l := marble.NewLexer(input)
+		for token := l.NextToken(); token.Type != marble.EOF; token = l.NextToken() {
+			fmt.Printf("%v:%v\t%v\t%q\n", token.LineNumber, token.ColNumber, token.Type, token.Literal)
+		}
+		return
+	}
+
 	l := marble.NewLexer(input)
 	p := marble.NewParser(l)
 	program := p.ParseProgram()
@@ -38,10 +84,113 @@ func main() {
 		fmt.Println("parsing errors, ", errors)
 		return
 	}
-	evaluated := marble.Eval(program, marble.NewEnvironment())
+	if ast {
+		fmt.Print(program.Tree())
+		return
+	}
+	if astJSON {
+		output, err := json.MarshalIndent(program.Tree(), "", "  ")
+		if err != nil {
+			fmt.Println("unable to encode AST, ", err)
+			return
+		}
+		fmt.Println(string(output))
+		return
+	}
+	// the tree printed by -ast and -ast-json is the parsed program, the optimized program is transpiled or run
+	if optimize {
+		program = marble.Optimize(program)
+	}
+	if transpile {
+		source, err := marble.Transpile(program)
+		if err != nil {
+			fmt.Println("unable to transpile program, ", err)
+			os.Exit(1)
+		}
+		fmt.Print(string(source))
+		return
+	}
+	capabilities.Read = allowRead
+	capabilities.Write = allowWrite
+	capabilities.Args = flag.Args()
+	env := marble.NewEnvironment()
+	env.LoadOS(capabilities)
+	if debug {
+		env.Trace(marble.NewDebugger(input, os.Stdin, os.Stdout))
+	}
+	var profiler *marble.Profiler
+	if profile || coverProfile != "" {
+		profiler = marble.NewProfiler(program)
+		env.Trace(profiler)
+	}
+	if loadState != "" {
+		if err := readState(env, loadState); err != nil {
+			fmt.Println("unable to load state, ", err)
+			os.Exit(1)
+		}
+	}
+	evaluated := marble.Eval(program, env)
 	var actuatlOutput string
 	if evaluated != nil {
 		actuatlOutput = evaluated.String()
 	}
 	fmt.Println(actuatlOutput)
+
+	if profile {
+		if err := profiler.WriteReport(os.Stderr); err != nil {
+			fmt.Println("unable to write profile, ", err)
+		}
+	}
+	if coverProfile != "" {
+		if err := writeCoverage(profiler, coverProfile, filepath); err != nil {
+			fmt.Println("unable to write coverage profile, ", err)
+		}
+	}
+	if saveState != "" {
+		if err := writeState(env, saveState); err != nil {
+			fmt.Println("unable to save state, ", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func writeCoverage(profiler *marble.Profiler, path, filepath string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return profiler.WriteCoverage(file, filepath)
+}
+
+func readState(env interface{ LoadState(io.Reader) error }, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return env.LoadState(file)
+}
+
+// writeState saves the state to a temporary file in the directory of path and renames it over path, so a failed save
+// leaves the previous state intact.
+func writeState(env interface{ SaveState(io.Writer) error }, path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if err := env.SaveState(file); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	return nil
 }
@@ -20,6 +20,11 @@ type expression interface {
 	expressionNode()
 }
 
+type pattern interface {
+	node
+	patternNode()
+}
+
 type program struct {
 	statements []statement
 }
@@ -35,9 +40,9 @@ func (p *program) String() string {
 }
 
 type varStatement struct {
-	token Token // VARIABLE token
-	name  *identifier
-	value expression
+	token  Token   // VARIABLE or CONSTANT token
+	target pattern // an identifier or a destructuring pattern, e.g. [a, b] or {name, age}
+	value  expression
 }
 
 func (s *varStatement) node()          {}
@@ -47,7 +52,7 @@ func (s *varStatement) String() string {
 	var output strings.Builder
 	_, _ = output.WriteString(s.token.Literal)
 	_, _ = output.WriteString(" ")
-	_, _ = output.WriteString(s.name.String())
+	_, _ = output.WriteString(s.target.String())
 	_, _ = output.WriteString(" = ")
 	_, _ = output.WriteString(s.value.String())
 	_, _ = output.WriteString(";")
@@ -86,9 +91,34 @@ func (s *expressionStatement) String() string {
 	return output.String()
 }
 
+type structStatement struct {
+	token  Token // STRUCT token
+	name   *identifier
+	fields []*identifier
+}
+
+func (s *structStatement) node()          {}
+func (s *structStatement) statementNode() {}
+
+func (s *structStatement) String() string {
+	var output strings.Builder
+	fields := make([]string, len(s.fields))
+	for i := range s.fields {
+		fields[i] = s.fields[i].String()
+	}
+	_, _ = output.WriteString(s.token.Literal)
+	_, _ = output.WriteString(" ")
+	_, _ = output.WriteString(s.name.String())
+	_, _ = output.WriteString(" {")
+	_, _ = output.WriteString(strings.Join(fields, ", "))
+	_, _ = output.WriteString("}")
+	return output.String()
+}
+
 type blockStatement struct {
 	token      Token // LBRACE token
 	statements []statement
+	layout     *layout // set by the resolver when the block is evaluated in its own environment
 }
 
 func (s *blockStatement) node()          {}
@@ -105,7 +135,10 @@ func (s *blockStatement) String() string {
 }
 
 type identifier struct {
-	token Token // IDENTIFIER token
+	token    Token // IDENTIFIER token
+	resolved bool  // set by the resolver when a scope declares the identifier
+	depth    int   // number of environments between the reference and the declaring scope
+	slot     int   // index of the identifier in the layout of the declaring scope
 }
 
 func (e *identifier) node()           {}
@@ -152,8 +185,22 @@ func (e *booleanLiteral) node()           {}
 func (e *booleanLiteral) expressionNode() {}
 func (e *booleanLiteral) String() string  { return e.token.Literal }
 
+type nullLiteral struct {
+	token Token // NULL token
+}
+
+func (e *nullLiteral) node()           {}
+func (e *nullLiteral) expressionNode() {}
+func (e *nullLiteral) String() string  { return "null" }
+
 type stringLiteral struct {
 	token Token // STRING token
+	value *objString
+}
+
+// newStringLiteral creates the literal with its value, every evaluation of the literal shares the value.
+func newStringLiteral(token Token) *stringLiteral {
+	return &stringLiteral{token: token, value: &objString{value: token.Literal}}
 }
 
 func (e *stringLiteral) node()           {}
@@ -180,6 +227,27 @@ func (e *arrayLiteral) String() string {
 	return output.String()
 }
 
+type mapLiteral struct {
+	token  Token // LBRACE token
+	keys   []expression
+	values []expression
+}
+
+func (e *mapLiteral) node()           {}
+func (e *mapLiteral) expressionNode() {}
+
+func (e *mapLiteral) String() string {
+	var output strings.Builder
+	pairs := make([]string, len(e.keys))
+	for i := range e.keys {
+		pairs[i] = e.keys[i].String() + ": " + e.values[i].String()
+	}
+	_, _ = output.WriteString("{")
+	_, _ = output.WriteString(strings.Join(pairs, ", "))
+	_, _ = output.WriteString("}")
+	return output.String()
+}
+
 type prefixExpression struct {
 	operator Token // SUBTRACT or NEGATE token
 	right    expression
@@ -247,9 +315,17 @@ func (e *ifExpression) String() string {
 }
 
 type functionExpression struct {
-	token      Token // FUNCTION token
-	parameters []*identifier
-	body       *blockStatement
+	token        Token // FUNCTION token
+	parameters   []*parameter
+	body         *blockStatement
+	receiver     *identifier // set for methods, e.g. p in func (p Point) norm() {}
+	receiverType *identifier
+	name         *identifier
+	arrow        bool // declared as (x) => x, token is the LPAREN token
+	generator    bool // the body yields
+
+	layout         *layout // set by the resolver, the parameters and identifiers declared in the body
+	receiverLayout *layout // set by the resolver for methods
 }
 
 func (e *functionExpression) node()           {}
@@ -265,7 +341,22 @@ func (e *functionExpression) String() string {
 	for i := range e.parameters {
 		params[i] = e.parameters[i].String()
 	}
+	if e.arrow {
+		_, _ = output.WriteString("(")
+		_, _ = output.WriteString(strings.Join(params, ", "))
+		_, _ = output.WriteString(") => ")
+		_, _ = output.WriteString(e.body.String())
+		return output.String()
+	}
 	_, _ = output.WriteString(e.token.Literal)
+	if e.receiver != nil {
+		_, _ = output.WriteString(" (")
+		_, _ = output.WriteString(e.receiver.String())
+		_, _ = output.WriteString(" ")
+		_, _ = output.WriteString(e.receiverType.String())
+		_, _ = output.WriteString(") ")
+		_, _ = output.WriteString(e.name.String())
+	}
 	_, _ = output.WriteString("(")
 	_, _ = output.WriteString(strings.Join(params, ", "))
 	_, _ = output.WriteString(")")
@@ -273,6 +364,48 @@ func (e *functionExpression) String() string {
 	return output.String()
 }
 
+type parameter struct {
+	target       pattern    // an identifier or a destructuring pattern
+	defaultValue expression // optional, e.g. y in func(x, y = 10) {}
+	variadic     bool       // collects the remaining arguments, e.g. rest in func(x, ...rest) {}
+}
+
+func (p *parameter) String() string {
+	switch {
+	case p.variadic:
+		return "..." + p.target.String()
+	case p.defaultValue != nil:
+		return p.target.String() + " = " + p.defaultValue.String()
+	}
+	return p.target.String()
+}
+
+// name returns the identifier of a parameter that is not destructured.
+func (p *parameter) name() (*identifier, bool) {
+	if binding, ok := p.target.(*bindingPattern); ok {
+		return binding.name, true
+	}
+	return nil, false
+}
+
+type spreadExpression struct {
+	token Token // ELLIPSIS token
+	value expression
+}
+
+func (e *spreadExpression) node()           {}
+func (e *spreadExpression) expressionNode() {}
+func (e *spreadExpression) String() string  { return "..." + e.value.String() }
+
+type namedArgument struct {
+	name  *identifier
+	value expression
+}
+
+func (e *namedArgument) node()           {}
+func (e *namedArgument) expressionNode() {}
+func (e *namedArgument) String() string  { return e.name.String() + ": " + e.value.String() }
+
 type callExpression struct {
 	token     Token // LPAREN token
 	function  expression
@@ -296,7 +429,7 @@ func (e *callExpression) String() string {
 }
 
 type indexExpression struct {
-	token Token // LBRACKET token
+	token Token // LBRACKET or OPTIONAL_LBRACKET token, an optional index of null is null
 	left  expression
 	index expression
 }
@@ -312,8 +445,220 @@ func (e *indexExpression) String() string {
 	var output strings.Builder
 	_, _ = output.WriteString("(")
 	_, _ = output.WriteString(e.left.String())
-	_, _ = output.WriteString("[")
+	_, _ = output.WriteString(e.token.Literal)
 	_, _ = output.WriteString(e.index.String())
 	_, _ = output.WriteString("])")
 	return output.String()
 }
+
+type memberExpression struct {
+	token  Token // DOT or OPTIONAL_DOT token, an optional member of null is null
+	left   expression
+	member *identifier
+}
+
+func (e *memberExpression) node()           {}
+func (e *memberExpression) expressionNode() {}
+
+func (e *memberExpression) String() string {
+	if e == nil {
+		return ""
+	}
+
+	var output strings.Builder
+	_, _ = output.WriteString("(")
+	_, _ = output.WriteString(e.left.String())
+	_, _ = output.WriteString(e.token.Literal)
+	_, _ = output.WriteString(e.member.String())
+	_, _ = output.WriteString(")")
+	return output.String()
+}
+
+type assignExpression struct {
+	token  Token // ASSIGN token
+	target expression
+	value  expression
+}
+
+func (e *assignExpression) node()           {}
+func (e *assignExpression) expressionNode() {}
+
+func (e *assignExpression) String() string {
+	var output strings.Builder
+	_, _ = output.WriteString("(")
+	_, _ = output.WriteString(e.target.String())
+	_, _ = output.WriteString(" = ")
+	_, _ = output.WriteString(e.value.String())
+	_, _ = output.WriteString(")")
+	return output.String()
+}
+
+type matchArm struct {
+	pattern pattern
+	body    *blockStatement
+	layout  *layout // set by the resolver, the identifiers bound by the pattern and declared in the body
+}
+
+func (a *matchArm) String() string { return a.pattern.String() + " => " + a.body.String() }
+
+type matchExpression struct {
+	token Token // MATCH token
+	value expression
+	arms  []*matchArm
+}
+
+func (e *matchExpression) node()           {}
+func (e *matchExpression) expressionNode() {}
+
+func (e *matchExpression) String() string {
+	if e == nil {
+		return ""
+	}
+
+	var output strings.Builder
+	arms := make([]string, len(e.arms))
+	for i := range e.arms {
+		arms[i] = e.arms[i].String()
+	}
+	_, _ = output.WriteString(e.token.Literal)
+	_, _ = output.WriteString(" (")
+	_, _ = output.WriteString(e.value.String())
+	_, _ = output.WriteString(") {")
+	_, _ = output.WriteString(strings.Join(arms, ", "))
+	_, _ = output.WriteString("}")
+	return output.String()
+}
+
+type literalPattern struct {
+	value expression // integer, float, string or boolean literal, optionally negated
+}
+
+func (p *literalPattern) node()          {}
+func (p *literalPattern) patternNode()   {}
+func (p *literalPattern) String() string { return p.value.String() }
+
+type wildcardPattern struct {
+	token Token // IDENTIFIER token with the literal _
+}
+
+func (p *wildcardPattern) node()          {}
+func (p *wildcardPattern) patternNode()   {}
+func (p *wildcardPattern) String() string { return p.token.Literal }
+
+type bindingPattern struct {
+	name *identifier
+}
+
+func (p *bindingPattern) node()          {}
+func (p *bindingPattern) patternNode()   {}
+func (p *bindingPattern) String() string { return p.name.String() }
+
+type arrayPattern struct {
+	token    Token // LBRACKET token
+	elements []pattern
+	rest     pattern // optional, collects the remaining elements, e.g. rest in [a, ...rest]
+}
+
+func (p *arrayPattern) node()        {}
+func (p *arrayPattern) patternNode() {}
+
+func (p *arrayPattern) String() string {
+	elements := make([]string, len(p.elements))
+	for i := range p.elements {
+		elements[i] = p.elements[i].String()
+	}
+	if p.rest != nil {
+		elements = append(elements, "..."+p.rest.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+type mapPattern struct {
+	token  Token   // LBRACE token
+	keys   []Token // IDENTIFIER or STRING tokens
+	values []pattern
+}
+
+func (p *mapPattern) node()        {}
+func (p *mapPattern) patternNode() {}
+
+func (p *mapPattern) String() string {
+	entries := make([]string, len(p.keys))
+	for i := range p.keys {
+		key := p.keys[i].Literal
+		if p.keys[i].Type == STRING {
+			key = fmt.Sprintf(`"%v"`, key)
+		}
+		if binding, ok := p.values[i].(*bindingPattern); ok && p.keys[i].Type == IDENTIFIER && binding.name.token.Literal == key {
+			entries[i] = key
+		} else {
+			entries[i] = key + ": " + p.values[i].String()
+		}
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+type alternativePattern struct {
+	alternatives []pattern
+}
+
+func (p *alternativePattern) node()        {}
+func (p *alternativePattern) patternNode() {}
+
+func (p *alternativePattern) String() string {
+	alternatives := make([]string, len(p.alternatives))
+	for i := range p.alternatives {
+		alternatives[i] = p.alternatives[i].String()
+	}
+	return strings.Join(alternatives, " | ")
+}
+
+type yieldExpression struct {
+	token    Token // YIELD token
+	value    expression
+	delegate bool // yields every element of a generator or array, e.g. yield ...other()
+}
+
+func (e *yieldExpression) node()           {}
+func (e *yieldExpression) expressionNode() {}
+
+func (e *yieldExpression) String() string {
+	if e == nil {
+		return ""
+	}
+
+	var output strings.Builder
+	_, _ = output.WriteString("(")
+	_, _ = output.WriteString(e.token.Literal)
+	_, _ = output.WriteString(" ")
+	if e.delegate {
+		_, _ = output.WriteString("...")
+	}
+	_, _ = output.WriteString(e.value.String())
+	_, _ = output.WriteString(")")
+	return output.String()
+}
+
+type regexLiteral struct {
+	token Token
+	value *objRegex // compiled by the parser so invalid patterns are reported with their position
+}
+
+func (e *regexLiteral) node()           {}
+func (e *regexLiteral) expressionNode() {}
+func (e *regexLiteral) String() string  { return e.token.Literal }
+
+type spawnExpression struct {
+	token Token // SPAWN token
+	call  *callExpression
+}
+
+func (e *spawnExpression) node()           {}
+func (e *spawnExpression) expressionNode() {}
+
+func (e *spawnExpression) String() string {
+	if e == nil {
+		return ""
+	}
+	return "(" + e.token.Literal + " " + e.call.String() + ")"
+}
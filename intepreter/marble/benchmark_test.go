@@ -0,0 +1,65 @@
+package marble_test
+
+import (
+	"testing"
+
+	eval "github.com/o-richard/intepreter/marble"
+)
+
+func benchmarkProgram(b *testing.B, input, output string) {
+	b.Helper()
+	p := eval.NewParser(eval.NewLexer([]byte(input)))
+	program := p.ParseProgram()
+	if issues := p.Errors(); len(issues) != 0 {
+		b.Fatalf("unexpected errors: %v", issues)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if evaluated := eval.Eval(program, eval.NewEnvironment()); evaluated.String() != output {
+			b.Fatalf("unexpected output, got=%v want=%v", evaluated.String(), output)
+		}
+	}
+}
+
+func BenchmarkArithmetic(b *testing.B) {
+	benchmarkProgram(b, `
+var sum = func(n, total) {
+    if (n == 0) {
+        return total;
+    }
+    sum(n - 1, total + n * 2 - n / 2 + 1)
+};
+sum(500, 0)`, "188500")
+}
+
+func BenchmarkRecursion(b *testing.B) {
+	benchmarkProgram(b, `
+var fib = func(n) {
+    if (n < 2) {
+        return n;
+    }
+    fib(n - 1) + fib(n - 2)
+};
+fib(15)`, "610")
+}
+
+func BenchmarkClosures(b *testing.B) {
+	benchmarkProgram(b, `
+var counter = func() {
+    var count = 0;
+    var next = func(step) {
+        count = count + step;
+        count
+    };
+    next
+};
+var repeat = func(f, n) {
+    if (n == 0) {
+        return f(0);
+    }
+    f(1);
+    repeat(f, n - 1)
+};
+repeat(counter(), 300)`, "300")
+}
@@ -0,0 +1,160 @@
+package marble
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// objTask is a function call running on its own goroutine, its result is available once done is closed.
+type objTask struct {
+	name   string
+	done   chan struct{}
+	result object
+}
+
+func (o *objTask) objectType() string { return TASK_OBJ }
+
+func (o *objTask) String() string {
+	if o.name == "" {
+		return "task"
+	}
+	return "task " + o.name
+}
+
+// runningTasks counts the spawned tasks that have not completed. Without running tasks, a send or recv that cannot
+// proceed immediately would block forever.
+var runningTasks atomic.Int64
+
+// spawn applies the function on a new goroutine, the function and arguments are evaluated by the caller.
+func spawn(token Token, function object, args []object, named map[string]object, caller *environment) object {
+	task := &objTask{done: make(chan struct{})}
+	if function, ok := function.(*objFunction); ok {
+		task.name = function.name
+	}
+	runningTasks.Add(1)
+	go func() {
+		defer close(task.done)
+		defer runningTasks.Add(-1)
+		task.result = applyFunction(token, function, args, named, caller)
+	}()
+	return task
+}
+
+type objChannel struct {
+	values chan object
+}
+
+func (o *objChannel) objectType() string { return CHANNEL_OBJ }
+func (o *objChannel) String() string     { return fmt.Sprintf("channel(%v)", cap(o.values)) }
+
+func builtinChannel(token Token, args ...object) object {
+	if len(args) > 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	var capacity int64
+	if len(args) == 1 {
+		arg, ok := args[0].(*objInteger)
+		if !ok {
+			return newError(token, "invalid argument type: %v", args[0].objectType())
+		}
+		if arg.value < 0 {
+			return newError(token, "invalid channel capacity: %v", arg.value)
+		}
+		capacity = arg.value
+	}
+	return &objChannel{values: make(chan object, capacity)}
+}
+
+func builtinSend(token Token, args ...object) (result object) {
+	if maxArgs := 2; len(args) != maxArgs {
+		return newError(token, "wrong number of arguments")
+	}
+	channel, ok := args[0].(*objChannel)
+	if !ok {
+		return newError(token, "invalid argument type: %v", args[0].objectType())
+	}
+	defer func() {
+		if recover() != nil {
+			result = newError(token, "send on closed channel")
+		}
+	}()
+	if runningTasks.Load() == 0 {
+		select {
+		case channel.values <- args[1]:
+			return objectNull
+		default:
+			return newError(token, "send on a full channel without running tasks would block forever")
+		}
+	}
+	channel.values <- args[1]
+	return objectNull
+}
+
+// builtinRecv blocks until a value is sent, it evaluates to null once the channel is closed and drained. Waiting
+// without running tasks to send a value is an error, the tasks are counted first so that the values they sent before
+// completing are received.
+func builtinRecv(token Token, args ...object) object {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	channel, ok := args[0].(*objChannel)
+	if !ok {
+		return newError(token, "invalid argument type: %v", args[0].objectType())
+	}
+	var value object
+	if runningTasks.Load() == 0 {
+		select {
+		case value, ok = <-channel.values:
+		default:
+			return newError(token, "recv on an empty channel without running tasks would block forever")
+		}
+	} else {
+		value, ok = <-channel.values
+	}
+	if !ok {
+		return objectNull
+	}
+	return value
+}
+
+func builtinClose(token Token, args ...object) (result object) {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	channel, ok := args[0].(*objChannel)
+	if !ok {
+		return newError(token, "invalid argument type: %v", args[0].objectType())
+	}
+	defer func() {
+		if recover() != nil {
+			result = newError(token, "close of closed channel")
+		}
+	}()
+	close(channel.values)
+	return objectNull
+}
+
+// builtinWait blocks until a task completes and evaluates to its result, an array of tasks evaluates to an array of results.
+func builtinWait(token Token, args ...object) object {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	switch arg := args[0].(type) {
+	case *objTask:
+		<-arg.done
+		return arg.result
+	case *objArray:
+		results := make([]object, len(arg.elements))
+		for i := range arg.elements {
+			if _, ok := arg.elements[i].(*objTask); !ok {
+				return newError(token, "invalid argument type: %v", arg.elements[i].objectType())
+			}
+			results[i] = builtinWait(token, arg.elements[i])
+			if _, ok := results[i].(*objError); ok {
+				return results[i]
+			}
+		}
+		return &objArray{elements: results}
+	}
+	return newError(token, "invalid argument type: %v", args[0].objectType())
+}
@@ -0,0 +1,114 @@
+package marble
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+func builtinType(token Token, args ...object) object {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	return &objString{value: args[0].objectType()}
+}
+
+func builtinInt(token Token, args ...object) object {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	switch arg := args[0].(type) {
+	case *objInteger:
+		return arg
+	case *objFloat:
+		if math.IsNaN(arg.value) || math.IsInf(arg.value, 0) || arg.value >= math.MaxInt64 || arg.value < math.MinInt64 {
+			return newError(token, "could not convert %v to integer", arg.value)
+		}
+		return newInteger(int64(arg.value))
+	case *objString:
+		value, err := strconv.ParseInt(strings.TrimSpace(arg.value), 10, 64)
+		if err != nil {
+			return newError(token, "could not parse '%v' as integer", arg.value)
+		}
+		return newInteger(value)
+	case *objBoolean:
+		if arg.value {
+			return newInteger(1)
+		}
+		return newInteger(0)
+	}
+	return newError(token, "could not convert %v to %v", args[0].objectType(), INTEGER_OBJ)
+}
+
+func builtinFloat(token Token, args ...object) object {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	switch arg := args[0].(type) {
+	case *objInteger:
+		return &objFloat{value: float64(arg.value)}
+	case *objFloat:
+		return arg
+	case *objString:
+		value, err := strconv.ParseFloat(strings.TrimSpace(arg.value), 64)
+		if err != nil {
+			return newError(token, "could not parse '%v' as float", arg.value)
+		}
+		return &objFloat{value: value}
+	case *objBoolean:
+		if arg.value {
+			return &objFloat{value: 1}
+		}
+		return &objFloat{value: 0}
+	}
+	return newError(token, "could not convert %v to %v", args[0].objectType(), FLOAT_OBJ)
+}
+
+func builtinStr(token Token, args ...object) object {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	if arg, ok := args[0].(*objString); ok {
+		return arg
+	}
+	return &objString{value: args[0].String()}
+}
+
+func builtinBool(token Token, args ...object) object {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	switch arg := args[0].(type) {
+	case *objBoolean:
+		return arg
+	case *objNull:
+		return objectFalse
+	case *objInteger:
+		return evalBoolean(arg.value != 0)
+	case *objFloat:
+		return evalBoolean(arg.value != 0)
+	case *objString:
+		value, err := strconv.ParseBool(strings.TrimSpace(arg.value))
+		if err != nil {
+			return newError(token, "could not parse '%v' as boolean", arg.value)
+		}
+		return evalBoolean(value)
+	}
+	return newError(token, "could not convert %v to %v", args[0].objectType(), BOOLEAN_OBJ)
+}
+
+func typePredicate(types ...string) *objBuiltin {
+	return &objBuiltin{
+		function: func(token Token, args ...object) object {
+			if len(args) != 1 {
+				return newError(token, "wrong number of arguments")
+			}
+			for i := range types {
+				if kind(args[0]) == types[i] {
+					return objectTrue
+				}
+			}
+			return objectFalse
+		},
+	}
+}
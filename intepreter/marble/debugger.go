@@ -0,0 +1,159 @@
+package marble
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type debugMode int
+
+const (
+	debugStep     debugMode = iota // pause before the next statement
+	debugNext                      // pause before the next statement that is not in a nested call
+	debugContinue                  // pause at breakpoints only
+	debugDetached                  // never pause, the debugger input is exhausted
+)
+
+// Debugger pauses the evaluation before statements and reads commands from its input.
+// It starts paused before the first statement of the program.
+type Debugger struct {
+	mu          sync.Mutex
+	lines       []string
+	input       *bufio.Scanner
+	output      io.Writer
+	mode        debugMode
+	frame       *frame // frame the next command was issued in
+	breakpoints map[int]bool
+}
+
+func NewDebugger(source []byte, input io.Reader, output io.Writer) *Debugger {
+	return &Debugger{
+		lines:       strings.Split(string(source), "\n"),
+		input:       bufio.NewScanner(input),
+		output:      output,
+		breakpoints: make(map[int]bool),
+	}
+}
+
+func (d *Debugger) beforeStatement(s statement, env *environment) *objError {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	token := statementToken(s)
+	switch {
+	case d.mode == debugDetached:
+		return nil
+	case d.breakpoints[token.LineNumber]:
+		_, _ = fmt.Fprintf(d.output, "breakpoint at line %v\n", token.LineNumber)
+	case d.mode == debugStep:
+	case d.mode == debugNext && d.returnedTo(env.frame):
+	default:
+		return nil
+	}
+
+	d.printLine(token.LineNumber)
+	for {
+		_, _ = fmt.Fprint(d.output, "(debug) ")
+		if !d.input.Scan() {
+			d.mode = debugDetached
+			return nil
+		}
+		command, argument, _ := strings.Cut(strings.TrimSpace(d.input.Text()), " ")
+		argument = strings.TrimSpace(argument)
+		switch command {
+		case "s", "step":
+			d.mode = debugStep
+			return nil
+		case "n", "next":
+			d.mode, d.frame = debugNext, env.frame
+			return nil
+		case "c", "continue":
+			d.mode = debugContinue
+			return nil
+		case "b", "break", "d", "delete":
+			line, err := strconv.Atoi(argument)
+			if err != nil || line < 1 {
+				_, _ = fmt.Fprintf(d.output, "invalid line '%v'\n", argument)
+				continue
+			}
+			d.breakpoints[line] = command == "b" || command == "break"
+		case "l", "list":
+			d.printLine(token.LineNumber)
+		case "p", "print":
+			if value, ok := env.get(argument); ok {
+				_, _ = fmt.Fprintln(d.output, value.String())
+			} else {
+				_, _ = fmt.Fprintf(d.output, "identifier '%v' not found\n", argument)
+			}
+		case "e", "env":
+			d.printEnvironment(env)
+		case "bt", "backtrace":
+			d.printBacktrace(token, env.frame)
+		case "q", "quit":
+			d.mode = debugDetached
+			return newError(token, "execution stopped by the debugger")
+		case "h", "help":
+			_, _ = fmt.Fprintln(d.output, "commands: step, next, continue, break <line>, delete <line>, list, print <name>, env, backtrace, quit")
+		default:
+			_, _ = fmt.Fprintf(d.output, "unknown command '%v', try help\n", command)
+		}
+	}
+}
+
+func (d *Debugger) call(*frame) func() { return nil }
+
+// returnedTo reports whether f is the frame the next command was issued in or one of its callers.
+func (d *Debugger) returnedTo(f *frame) bool {
+	for current := d.frame; current != nil; current = current.caller {
+		if current == f {
+			return true
+		}
+	}
+	return f == nil
+}
+
+func (d *Debugger) printLine(line int) {
+	var source string
+	if line > 0 && line <= len(d.lines) {
+		source = strings.TrimSpace(d.lines[line-1])
+	}
+	_, _ = fmt.Fprintf(d.output, "line %v: %v\n", line, source)
+}
+
+// printEnvironment prints the identifiers of each scope from the innermost outwards, built-in functions are omitted.
+func (d *Debugger) printEnvironment(env *environment) {
+	for depth := 0; env != nil; depth, env = depth+1, env.outer {
+		bindings := make([]string, 0)
+		for name, value := range env.bindings() {
+			if _, ok := value.(*objBuiltin); !ok {
+				bindings = append(bindings, name+" = "+value.String())
+			}
+		}
+		slices.Sort(bindings)
+		scope := fmt.Sprintf("scope %v", depth)
+		if env.outer == nil {
+			scope = "global scope"
+		}
+		_, _ = fmt.Fprintf(d.output, "%v: %v\n", scope, strings.Join(bindings, ", "))
+	}
+}
+
+// printBacktrace prints the active function calls from the innermost outwards, each with the position it is paused at.
+func (d *Debugger) printBacktrace(token Token, f *frame) {
+	for i := 0; ; i++ {
+		function := "main"
+		if f != nil {
+			function = f.function
+		}
+		_, _ = fmt.Fprintf(d.output, "#%v %v at line %v column %v\n", i, function, token.LineNumber, token.ColNumber)
+		if f == nil {
+			return
+		}
+		token, f = f.token, f.caller
+	}
+}
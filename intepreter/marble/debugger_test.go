@@ -0,0 +1,50 @@
+package marble_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	eval "github.com/o-richard/intepreter/marble"
+)
+
+func TestDebugger(t *testing.T) {
+	input := `var fib = func(n) {
+    if (n <= 1) {
+        return n;
+    }
+    return fib(n - 1) + fib(n - 2);
+};
+var total = fib(2);
+total;`
+	tests := []struct {
+		name, commands, output, result string
+	}{
+		{name: "step", commands: "step\ns\ns\n", output: "line 1: var fib = func(n) {\n(debug) line 7: var total = fib(2);\n(debug) line 2: if (n <= 1) {\n(debug) ", result: "1"},
+		{name: "next", commands: "next\nnext\nnext\n", output: "line 1: var fib = func(n) {\n(debug) line 7: var total = fib(2);\n(debug) line 8: total;\n(debug) ", result: "1"},
+		{name: "breakpoint", commands: "break 3\ncontinue\ncontinue\ndelete 3\ncontinue\n", output: "line 1: var fib = func(n) {\n(debug) (debug) breakpoint at line 3\nline 3: return n;\n(debug) breakpoint at line 3\nline 3: return n;\n(debug) (debug) ", result: "1"},
+		{name: "backtrace", commands: "b 3\nc\nbacktrace\nq\n", output: "#0 fib at line 3 column 9\n#1 fib at line 5 column 15\n#2 main at line 7 column 16\n", result: "execution stopped by the debugger"},
+		{name: "environment", commands: "b 3\nc\nenv\nprint n\nprint missing\nq\n", output: "scope 0: \nscope 1: n = 1\nglobal scope: fib = func(n)", result: "execution stopped by the debugger"},
+		{name: "invalid commands", commands: "b x\njump\nq\n", output: "invalid line 'x'\n(debug) unknown command 'jump', try help\n", result: "execution stopped by the debugger"},
+		{name: "print", commands: "b 8\nc\np total\np missing\nc\n", output: "line 8: total;\n(debug) 1\n(debug) identifier 'missing' not found\n", result: "1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := eval.NewParser(eval.NewLexer([]byte(input)))
+			program := p.ParseProgram()
+			if issues := p.Errors(); len(issues) != 0 {
+				t.Fatalf("unexpected errors: %v", issues)
+			}
+			var output bytes.Buffer
+			env := eval.NewEnvironment()
+			env.Trace(eval.NewDebugger([]byte(input), strings.NewReader(test.commands), &output))
+			evaluated := eval.Eval(program, env)
+			if !strings.Contains(evaluated.String(), test.result) {
+				t.Fatalf("unexpected result, got=%v want=%v", evaluated.String(), test.result)
+			}
+			if !strings.Contains(output.String(), test.output) {
+				t.Fatalf("unexpected output, got=%q want=%q", output.String(), test.output)
+			}
+		})
+	}
+}
@@ -1,26 +1,189 @@
 package marble
 
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	errUndeclared = errors.New("undeclared identifier")
+	errConstant   = errors.New("constant identifier")
+)
+
+// environment is safe for concurrent use, spawned functions share the environments captured by their closures.
+// Environments created for a resolved scope keep the identifiers of its layout in slots, every other identifier is
+// kept in store.
 type environment struct {
-	store map[string]object
-	outer *environment
+	mu        sync.RWMutex
+	layout    *layout
+	slots     []object // nil until the identifier is declared
+	inline    [4]object
+	store     map[string]object
+	constants map[string]bool // allocated by the first constant declaration
+	outer     *environment
+	generator *generatorState // set on the environment of a generator function call
+	tracer    tracer          // inherited by enclosed environments
+	random    *randomSource   // inherited by enclosed environments
+	frame     *frame          // the innermost function call, only recorded while tracing
 }
 
 func NewEnvironment() *environment {
-	return &environment{store: make(map[string]object)}
+	return &environment{store: make(map[string]object), random: newRandomSource()}
 }
 
 func newEnclosedEnvironment(outer *environment) *environment {
-	return &environment{store: make(map[string]object), outer: outer}
+	return &environment{outer: outer, tracer: outer.tracer, random: outer.random, frame: outer.frame}
+}
+
+// newScopedEnvironment creates an environment for a scope of the resolver, the layout may be nil.
+func newScopedEnvironment(outer *environment, l *layout) *environment {
+	env := newEnclosedEnvironment(outer)
+	if l != nil {
+		env.layout = l
+		if len(l.names) <= len(env.inline) {
+			env.slots = env.inline[:len(l.names)]
+		} else {
+			env.slots = make([]object, len(l.names))
+		}
+	}
+	return env
+}
+
+// slot returns the index of the identifier in the layout of the environment.
+func (e *environment) slot(key string) (int, bool) {
+	if e.layout == nil {
+		return 0, false
+	}
+	i, ok := e.layout.index[key]
+	return i, ok
+}
+
+// local returns the identifier declared in this environment, the caller holds the lock.
+func (e *environment) local(key string) (object, bool) {
+	if i, ok := e.slot(key); ok {
+		return e.slots[i], e.slots[i] != nil
+	}
+	value, ok := e.store[key]
+	return value, ok
 }
 
 func (e *environment) set(key string, value object) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if i, ok := e.slot(key); ok {
+		e.slots[i] = value
+		return
+	}
+	if e.store == nil {
+		e.store = make(map[string]object)
+	}
 	e.store[key] = value
 }
 
+// declare binds a new identifier in the current scope, it reports false if the identifier is already declared in it.
+func (e *environment) declare(key string, value object, constant bool) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.local(key); ok {
+		return false
+	}
+	if i, ok := e.slot(key); ok {
+		e.slots[i] = value
+	} else {
+		if e.store == nil {
+			e.store = make(map[string]object)
+		}
+		e.store[key] = value
+	}
+	if constant {
+		if e.constants == nil {
+			e.constants = make(map[string]bool)
+		}
+		e.constants[key] = true
+	}
+	return true
+}
+
+// assign rebinds an identifier in the closest scope that declares it.
+func (e *environment) assign(key string, value object) error {
+	for env := e; env != nil; env = env.outer {
+		if found, err := env.assignLocal(key, value); found {
+			return err
+		}
+	}
+	return errUndeclared
+}
+
+// assignLocal rebinds an identifier in the current scope, it reports false if the identifier is not declared in it.
+func (e *environment) assignLocal(key string, value object) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.local(key); !ok {
+		return false, nil
+	}
+	if e.constants[key] {
+		return true, errConstant
+	}
+	if i, ok := e.slot(key); ok {
+		e.slots[i] = value
+	} else {
+		e.store[key] = value
+	}
+	return true, nil
+}
+
+// ancestor returns the environment depth levels out, or the outermost one.
+func (e *environment) ancestor(depth int) *environment {
+	env := e
+	for ; depth > 0 && env.outer != nil; depth-- {
+		env = env.outer
+	}
+	return env
+}
+
+// getAt looks up the identifier in the slot of the environment depth levels out, the environments in between are
+// known not to declare it. An identifier that is not declared yet is looked up further out by name.
+func (e *environment) getAt(depth, slot int, key string) (object, bool) {
+	env := e.ancestor(depth)
+	if env.layout == nil || slot >= len(env.slots) || env.layout.names[slot] != key {
+		return env.get(key)
+	}
+	env.mu.RLock()
+	value := env.slots[slot]
+	env.mu.RUnlock()
+	if value != nil {
+		return value, true
+	}
+	if env.outer == nil {
+		return nil, false
+	}
+	return env.outer.get(key)
+}
+
 func (e *environment) get(key string) (object, bool) {
-	value, ok := e.store[key]
-	if !ok && e.outer != nil {
-		value, ok = e.outer.get(key)
+	for env := e; env != nil; env = env.outer {
+		env.mu.RLock()
+		value, ok := env.local(key)
+		env.mu.RUnlock()
+		if ok {
+			return value, true
+		}
 	}
-	return value, ok
+	return nil, false
+}
+
+// bindings returns the identifiers declared in this environment.
+func (e *environment) bindings() map[string]object {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	bindings := make(map[string]object, len(e.slots)+len(e.store))
+	for i := range e.slots {
+		if e.slots[i] != nil {
+			bindings[e.layout.names[i]] = e.slots[i]
+		}
+	}
+	for name, value := range e.store {
+		bindings[name] = value
+	}
+	return bindings
 }
@@ -1,6 +1,14 @@
 package marble
 
-import "fmt"
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+)
 
 var (
 	objectNull  = &objNull{}
@@ -13,30 +21,145 @@ var (
 		"len": {
 			function: func(token Token, args ...object) object {
 				if len(args) != 1 {
-					return &objError{message: fmt.Sprintf("line %v col %v: wrong number of arguments", token.LineNumber, token.ColNumber)}
+					return newError(token, "wrong number of arguments")
 				}
 				switch arg := args[0].(type) {
 				case *objArray:
-					return &objInteger{value: int64(len(arg.elements))}
+					return newInteger(int64(len(arg.elements)))
+				case *objMap:
+					return newInteger(int64(arg.len()))
 				case *objString:
-					return &objInteger{value: int64(len(arg.value))}
+					return newInteger(int64(len(arg.value)))
 				}
-				return &objError{message: fmt.Sprintf("line %v col %v: invalid argument type: %v", token.LineNumber, token.ColNumber, args[0].objectType())}
+				return newError(token, "invalid argument type: %v", args[0].objectType())
 			},
 		},
 		"push": {
 			function: func(token Token, args ...object) object {
 				if maxArgs := 2; len(args) < maxArgs {
-					return &objError{message: fmt.Sprintf("line %v col %v: wrong number of arguments", token.LineNumber, token.ColNumber)}
+					return newError(token, "wrong number of arguments")
 				}
 				switch arg := args[0].(type) {
 				case *objArray:
 					slice := append(make([]object, 0, len(arg.elements)+len(args)-1), arg.elements...)
 					return &objArray{elements: append(slice, args[1:]...)}
 				}
-				return &objError{message: fmt.Sprintf("line %v col %v: invalid argument type: %v", token.LineNumber, token.ColNumber, args[0].objectType())}
+				return newError(token, "invalid argument type: %v", args[0].objectType())
+			},
+		},
+		"json_parse": {
+			function: builtinJSONParse,
+		},
+		"json_stringify": {
+			function: builtinJSONStringify,
+		},
+		"same": {
+			function: func(token Token, args ...object) object {
+				if maxArgs := 2; len(args) != maxArgs {
+					return newError(token, "wrong number of arguments")
+				}
+				return evalBoolean(args[0] == args[1])
 			},
 		},
+		"type": {
+			function: builtinType,
+		},
+		"int": {
+			function: builtinInt,
+		},
+		"float": {
+			function: builtinFloat,
+		},
+		"str": {
+			function: builtinStr,
+		},
+		"bool": {
+			function: builtinBool,
+		},
+		"is_integer":  typePredicate(INTEGER_OBJ),
+		"is_float":    typePredicate(FLOAT_OBJ),
+		"is_number":   typePredicate(INTEGER_OBJ, FLOAT_OBJ),
+		"is_boolean":  typePredicate(BOOLEAN_OBJ),
+		"is_string":   typePredicate(STRING_OBJ),
+		"is_array":    typePredicate(ARRAY_OBJ),
+		"is_map":      typePredicate(MAP_OBJ),
+		"is_null":     typePredicate(NULL_OBJ),
+		"is_function": typePredicate(FUNCTION_OBJ, BUILTIN_OBJ),
+		"collect": {
+			function: builtinCollect,
+		},
+		"channel": {
+			function: builtinChannel,
+		},
+		"send": {
+			function: builtinSend,
+		},
+		"recv": {
+			function: builtinRecv,
+		},
+		"close": {
+			function: builtinClose,
+		},
+		"wait": {
+			function: builtinWait,
+		},
+		"assert": {
+			function: builtinAssert,
+		},
+		"assert_eq": {
+			function: builtinAssertEqual,
+		},
+		"abs": {
+			function: builtinAbs,
+		},
+		"floor": roundingFunction(math.Floor),
+		"ceil":  roundingFunction(math.Ceil),
+		"round": roundingFunction(math.Round),
+		"sqrt":  floatFunction(math.Sqrt, func(x float64) bool { return x >= 0 }),
+		"sin":   floatFunction(math.Sin, nil),
+		"cos":   floatFunction(math.Cos, nil),
+		"log":   floatFunction(math.Log, func(x float64) bool { return x > 0 }),
+		"pow": {
+			function: builtinPow,
+		},
+		"min": extremumFunction(func(x, y float64) bool { return x < y }),
+		"max": extremumFunction(func(x, y float64) bool { return x > y }),
+		"random": {
+			contextual: builtinRandom,
+		},
+		"seed": {
+			contextual: builtinSeed,
+		},
+		"now": {
+			function: builtinNow,
+		},
+		"sleep": {
+			function: builtinSleep,
+		},
+		"time_format": {
+			function: builtinTimeFormat,
+		},
+		"time_parse": {
+			function: builtinTimeParse,
+		},
+		"duration": {
+			function: builtinDuration,
+		},
+		"regex": {
+			function: builtinRegex,
+		},
+		"match_re": {
+			function: builtinMatch,
+		},
+		"find_all": {
+			function: builtinFindAll,
+		},
+		"replace_all": {
+			function: builtinReplaceAll,
+		},
+		"split_re": {
+			function: builtinSplitRegex,
+		},
 		"print": {
 			function: func(token Token, args ...object) object {
 				for i := range args {
@@ -57,7 +180,22 @@ func Eval(node node, env *environment) object {
 		if _, ok := value.(*objError); ok {
 			return value
 		}
-		env.set(node.name.token.Literal, value)
+		mismatch, err := bindPattern(node.target, value, env, node.token.Type == CONSTANT)
+		if err != nil {
+			return err
+		}
+		if mismatch != "" {
+			return newError(node.token, "cannot destructure %v into %v: %v", value.objectType(), node.target, mismatch)
+		}
+	case *structStatement:
+		fields := make([]string, len(node.fields))
+		for i := range node.fields {
+			fields[i] = node.fields[i].token.Literal
+		}
+		definition := &objStructType{name: node.name.token.Literal, fields: fields, methods: make(map[string]*structMethod)}
+		if !env.declare(node.name.token.Literal, definition, false) {
+			return newError(node.name.token, "identifier '%v' already declared", node.name.token.Literal)
+		}
 	case *returnStatement:
 		value := Eval(node.value, env)
 		if _, ok := value.(*objError); ok {
@@ -67,23 +205,34 @@ func Eval(node node, env *environment) object {
 	case *expressionStatement:
 		return Eval(node.value, env)
 	case *blockStatement:
-		return evalBlockStatement(node, env)
+		return evalBlockStatement(node, newScopedEnvironment(env, node.layout))
 	case *identifier:
+		if node.resolved {
+			if value, ok := env.getAt(node.depth, node.slot, node.token.Literal); ok {
+				return value
+			}
+		}
 		return evalIdentifier(node.token, env)
 	case *integerLiteral:
-		return &objInteger{value: node.value}
+		return newInteger(node.value)
 	case *floatLiteral:
 		return &objFloat{value: node.value}
+	case *nullLiteral:
+		return objectNull
 	case *booleanLiteral:
 		return evalBoolean(node.value)
 	case *stringLiteral:
-		return &objString{value: node.token.Literal}
+		return node.value
+	case *regexLiteral:
+		return node.value
 	case *arrayLiteral:
 		elements, ok := evalExpressions(node.elements, env)
 		if !ok {
 			return elements[0]
 		}
 		return &objArray{elements: elements}
+	case *mapLiteral:
+		return evalMapLiteral(node, env)
 	case *prefixExpression:
 		right := Eval(node.right, env)
 		if _, ok := right.(*objError); ok {
@@ -95,35 +244,84 @@ func Eval(node node, env *environment) object {
 		if _, ok := left.(*objError); ok {
 			return left
 		}
+		if node.operator.Type == COALESCE && left != objectNull {
+			return left
+		}
 		right := Eval(node.right, env)
 		if _, ok := right.(*objError); ok {
 			return right
 		}
-		return evalInfixExpression(node.operator, left, right)
+		if node.operator.Type == COALESCE {
+			return right
+		}
+		return evalInfixExpression(node.operator, left, right, env)
 	case *ifExpression:
 		return evalIfExpression(node, env)
+	case *blockExpression:
+		return Eval(node.block, env)
+	case *matchExpression:
+		return evalMatchExpression(node, env)
+	case *yieldExpression:
+		value := Eval(node.value, env)
+		if _, ok := value.(*objError); ok {
+			return value
+		}
+		return evalYieldExpression(node, value, env)
+	case *spawnExpression:
+		function := Eval(node.call.function, env)
+		if _, ok := function.(*objError); ok {
+			return function
+		}
+		args, named, err := evalArguments(node.call.arguments, env)
+		if err != nil {
+			return err
+		}
+		return spawn(node.call.token, function, args, named, env)
 	case *functionExpression:
-		return &objFunction{body: node.body, parameters: node.parameters, env: env}
+		if node.receiver != nil {
+			return evalMethodDeclaration(node, env)
+		}
+		function := &objFunction{generator: node.generator, body: node.body, parameters: node.parameters, env: env, layout: node.layout}
+		if node.name != nil {
+			function.name = node.name.token.Literal
+		}
+		return function
 	case *callExpression:
 		function := Eval(node.function, env)
 		if _, ok := function.(*objError); ok {
 			return function
 		}
-		args, ok := evalExpressions(node.arguments, env)
-		if !ok {
-			return args[0]
+		args, named, err := evalArguments(node.arguments, env)
+		if err != nil {
+			return err
 		}
-		return applyFunction(node.token, function, args)
+		return applyFunction(node.token, function, args, named, env)
 	case *indexExpression:
 		left := Eval(node.left, env)
 		if _, ok := left.(*objError); ok {
 			return left
 		}
+		if node.token.Type == OPTIONAL_LBRACKET && left == objectNull {
+			return objectNull
+		}
 		index := Eval(node.index, env)
 		if _, ok := index.(*objError); ok {
 			return index
 		}
 		return evalIndexExpression(node.token, left, index)
+	case *memberExpression:
+		left := Eval(node.left, env)
+		if _, ok := left.(*objError); ok {
+			return left
+		}
+		if node.token.Type == OPTIONAL_DOT && left == objectNull {
+			return objectNull
+		}
+		return evalMemberExpression(node, left)
+	case *assignExpression:
+		return evalAssignExpression(node, env)
+	case *nativeCode:
+		return node.evaluate(env)
 	}
 	return nil
 }
@@ -131,6 +329,11 @@ func Eval(node node, env *environment) object {
 func evalProgram(p *program, env *environment) object {
 	var result object
 	for i := range p.statements {
+		if env.tracer != nil {
+			if err := env.tracer.beforeStatement(p.statements[i], env); err != nil {
+				return err
+			}
+		}
 		result = Eval(p.statements[i], env)
 
 		switch result := result.(type) {
@@ -146,6 +349,11 @@ func evalProgram(p *program, env *environment) object {
 func evalBlockStatement(b *blockStatement, env *environment) object {
 	var result object
 	for i := range b.statements {
+		if env.tracer != nil {
+			if err := env.tracer.beforeStatement(b.statements[i], env); err != nil {
+				return err
+			}
+		}
 		result = Eval(b.statements[i], env)
 
 		switch result := result.(type) {
@@ -167,7 +375,7 @@ func evalIdentifier(token Token, env *environment) object {
 	if ok {
 		return value
 	}
-	return &objError{message: fmt.Sprintf("line %v col %v: identifier '%v' not found", token.LineNumber, token.ColNumber, token.Literal)}
+	return newError(token, "identifier '%v' not found", token.Literal)
 }
 
 func evalBoolean(b bool) *objBoolean {
@@ -187,28 +395,65 @@ func evalPrefixExpression(operator Token, right object) object {
 	case "-":
 		switch right := right.(type) {
 		case *objInteger:
-			return &objInteger{value: -right.value}
+			return newInteger(-right.value)
 		case *objFloat:
 			return &objFloat{value: -right.value}
 		}
 	}
-	return &objError{message: fmt.Sprintf("line %v col %v: unknown operator: %v%v", operator.LineNumber, operator.ColNumber, operator.Literal, right.objectType())}
+	return newError(operator, "unknown operator: %v%v", operator.Literal, right.objectType())
+}
+
+// operatorMethods are the struct methods called for infix operators, e.g. a + b calls a.__add__(b).
+var operatorMethods = map[string]string{
+	"+":  "__add__",
+	"-":  "__sub__",
+	"*":  "__mul__",
+	"/":  "__div__",
+	"==": "__eq__",
+	"!=": "__ne__",
+	"<":  "__lt__",
+	">":  "__gt__",
+	"<=": "__le__",
+	">=": "__ge__",
 }
 
-func evalInfixExpression(operator Token, left, right object) object {
+// evalInfixExpression applies the operator, env is the environment of the expression and is only used to call
+// operator methods of structs. Only the methods of the left operand are called, and a struct without a __ne__ method
+// negates the result of its __eq__ method.
+func evalInfixExpression(operator Token, left, right object, env *environment) object {
+	if left, ok := left.(*objStruct); ok {
+		if method, ok := left.definition.method(operatorMethods[operator.Literal]); ok {
+			return applyFunction(operator, bindMethod(method, left), []object{right}, nil, env)
+		}
+		if method, ok := left.definition.method("__eq__"); ok && operator.Literal == "!=" {
+			equal := applyFunction(operator, bindMethod(method, left), []object{right}, nil, env)
+			if _, ok := equal.(*objError); ok {
+				return equal
+			}
+			return evalPrefixExpression(Token{Type: NEGATE, Literal: "!"}, equal)
+		}
+	}
+
+	leftKind, rightKind := kind(left), kind(right)
 	switch {
-	case left.objectType() == INTEGER && right.objectType() == INTEGER:
+	case leftKind == INTEGER_OBJ && rightKind == INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
-	case (left.objectType() == FLOAT || left.objectType() == INTEGER) && (right.objectType() == FLOAT || right.objectType() == INTEGER):
+	case (leftKind == FLOAT_OBJ || leftKind == INTEGER_OBJ) && (rightKind == FLOAT_OBJ || rightKind == INTEGER_OBJ):
 		return evalFloatInfixExpression(operator, left, right)
-	case left.objectType() == STRING && right.objectType() == STRING:
+	case leftKind == STRING_OBJ && rightKind == STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case leftKind == ARRAY_OBJ && rightKind == ARRAY_OBJ:
+		return evalArrayInfixExpression(operator, left, right)
+	case operator.Literal == "*" && rightKind == INTEGER_OBJ && (leftKind == ARRAY_OBJ || leftKind == STRING_OBJ):
+		return evalRepetition(operator, left, right.(*objInteger).value)
+	case operator.Literal == "*" && leftKind == INTEGER_OBJ && (rightKind == ARRAY_OBJ || rightKind == STRING_OBJ):
+		return evalRepetition(operator, right, left.(*objInteger).value)
 	case operator.Literal == "==":
-		return evalBoolean(left == right)
+		return evalBoolean(objectsEqual(left, right))
 	case operator.Literal == "!=":
-		return evalBoolean(left != right)
+		return evalBoolean(!objectsEqual(left, right))
 	}
-	return &objError{message: fmt.Sprintf("line %v col %v: unknown operator: %v %v %v", operator.LineNumber, operator.ColNumber, left.objectType(), operator.Literal, right.objectType())}
+	return newError(operator, "unknown operator: %v %v %v", left.objectType(), operator.Literal, right.objectType())
 }
 
 func evalIntegerInfixExpression(operator Token, left, right object) object {
@@ -217,16 +462,16 @@ func evalIntegerInfixExpression(operator Token, left, right object) object {
 
 	switch operator.Literal {
 	case "+":
-		return &objInteger{value: leftValue + rightValue}
+		return newInteger(leftValue + rightValue)
 	case "-":
-		return &objInteger{value: leftValue - rightValue}
+		return newInteger(leftValue - rightValue)
 	case "*":
-		return &objInteger{value: leftValue * rightValue}
+		return newInteger(leftValue * rightValue)
 	case "/":
 		if rightValue == 0 {
-			return &objError{message: fmt.Sprintf("line %v col %v: invalid division by zero", operator.LineNumber, operator.ColNumber)}
+			return newError(operator, "invalid division by zero")
 		}
-		return &objInteger{value: leftValue / rightValue}
+		return newInteger(leftValue / rightValue)
 	case "<":
 		return evalBoolean(leftValue < rightValue)
 	case ">":
@@ -240,7 +485,7 @@ func evalIntegerInfixExpression(operator Token, left, right object) object {
 	case "!=":
 		return evalBoolean(leftValue != rightValue)
 	}
-	return &objError{message: fmt.Sprintf("line %v col %v: unknown operator: %v %v %v", operator.LineNumber, operator.ColNumber, left.objectType(), operator.Literal, right.objectType())}
+	return newError(operator, "unknown operator: %v %v %v", left.objectType(), operator.Literal, right.objectType())
 }
 
 func evalFloatInfixExpression(operator Token, left, right object) object {
@@ -268,7 +513,7 @@ func evalFloatInfixExpression(operator Token, left, right object) object {
 		return &objFloat{value: leftValue * rightValue}
 	case "/":
 		if rightValue == 0 {
-			return &objError{message: fmt.Sprintf("line %v col %v: invalid division by zero", operator.LineNumber, operator.ColNumber)}
+			return newError(operator, "invalid division by zero")
 		}
 		return &objFloat{value: leftValue / rightValue}
 	case "<":
@@ -284,7 +529,7 @@ func evalFloatInfixExpression(operator Token, left, right object) object {
 	case "!=":
 		return evalBoolean(leftValue != rightValue)
 	}
-	return &objError{message: fmt.Sprintf("line %v col %v: unknown operator: %v %v %v", operator.LineNumber, operator.ColNumber, left.objectType(), operator.Literal, right.objectType())}
+	return newError(operator, "unknown operator: %v %v %v", left.objectType(), operator.Literal, right.objectType())
 }
 
 func evalStringInfixExpression(operator Token, left, right object) object {
@@ -294,12 +539,196 @@ func evalStringInfixExpression(operator Token, left, right object) object {
 	switch operator.Literal {
 	case "+":
 		return &objString{value: leftValue + rightValue}
+	case "<":
+		return evalBoolean(leftValue < rightValue)
+	case ">":
+		return evalBoolean(leftValue > rightValue)
+	case ">=":
+		return evalBoolean(leftValue >= rightValue)
+	case "<=":
+		return evalBoolean(leftValue <= rightValue)
 	case "==":
 		return evalBoolean(leftValue == rightValue)
 	case "!=":
 		return evalBoolean(leftValue != rightValue)
 	}
-	return &objError{message: fmt.Sprintf("line %v col %v: unknown operator: %v %v %v", operator.LineNumber, operator.ColNumber, left.objectType(), operator.Literal, right.objectType())}
+	return newError(operator, "unknown operator: %v %v %v", left.objectType(), operator.Literal, right.objectType())
+}
+
+func evalArrayInfixExpression(operator Token, left, right object) object {
+	switch operator.Literal {
+	case "+":
+		return &objArray{elements: slices.Concat(left.(*objArray).elements, right.(*objArray).elements)}
+	case "==":
+		return evalBoolean(objectsEqual(left, right))
+	case "!=":
+		return evalBoolean(!objectsEqual(left, right))
+	case "<", ">", "<=", ">=":
+		comparison, ok := compareObjects(left, right)
+		if !ok {
+			return newError(operator, "unable to compare elements: %v %v %v", left, operator.Literal, right)
+		}
+		if comparison == unordered {
+			return objectFalse
+		}
+		switch operator.Literal {
+		case "<":
+			return evalBoolean(comparison < 0)
+		case ">":
+			return evalBoolean(comparison > 0)
+		case "<=":
+			return evalBoolean(comparison <= 0)
+		default:
+			return evalBoolean(comparison >= 0)
+		}
+	}
+	return newError(operator, "unknown operator: %v %v %v", left.objectType(), operator.Literal, right.objectType())
+}
+
+// evalRepetition repeats the elements of an array or the characters of a string count times.
+// maxRepetitionLength limits the elements of an array or the bytes of a string created by repetition.
+const maxRepetitionLength = 1 << 24
+
+func evalRepetition(operator Token, value object, count int64) object {
+	var size int
+	switch value := value.(type) {
+	case *objArray:
+		size = len(value.elements)
+	case *objString:
+		size = len(value.value)
+	}
+	if count < 0 {
+		return newError(operator, "invalid repetition count %v", count)
+	}
+	if size > 0 && count > int64(maxRepetitionLength/size) {
+		return newError(operator, "repetition of %v by %v is too large", value.objectType(), count)
+	}
+	switch value := value.(type) {
+	case *objArray:
+		return &objArray{elements: slices.Repeat(value.elements, int(count))}
+	case *objString:
+		return &objString{value: strings.Repeat(value.value, int(count))}
+	}
+	return newError(operator, "unknown operator: %v %v %v", value.objectType(), operator.Literal, INTEGER_OBJ)
+}
+
+// objectsEqual compares values structurally, functions and builtins are only equal to themselves.
+func objectsEqual(left, right object) bool {
+	if left, ok := left.(*objFloat); ok && math.IsNaN(left.value) {
+		return false
+	}
+	if left == right {
+		return true
+	}
+	switch left := left.(type) {
+	case *objInteger, *objFloat:
+		comparison, ok := compareObjects(left, right)
+		return ok && comparison == 0
+	case *objString:
+		right, ok := right.(*objString)
+		return ok && left.value == right.value
+	case *objBoolean:
+		right, ok := right.(*objBoolean)
+		return ok && left.value == right.value
+	case *objNull:
+		_, ok := right.(*objNull)
+		return ok
+	case *objRegex:
+		right, ok := right.(*objRegex)
+		return ok && left.source == right.source
+	case *objArray:
+		right, ok := right.(*objArray)
+		if !ok || len(left.elements) != len(right.elements) {
+			return false
+		}
+		for i := range left.elements {
+			if !objectsEqual(left.elements[i], right.elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *objMap:
+		right, ok := right.(*objMap)
+		if !ok || left.len() != right.len() {
+			return false
+		}
+		keys, values := left.entries()
+		for i := range keys {
+			other, ok := right.get(keys[i])
+			if !ok || !objectsEqual(values[i], other) {
+				return false
+			}
+		}
+		return true
+	case *objStruct:
+		right, ok := right.(*objStruct)
+		if !ok || left.definition != right.definition {
+			return false
+		}
+		values, others := left.fieldValues(), right.fieldValues()
+		for i := range values {
+			if !objectsEqual(values[i], others[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// unordered is the comparison of NaN with a number, like the comparison operators on numbers it is neither less,
+// equal nor greater.
+const unordered = 2
+
+// compareFloats compares the numbers like the comparison operators do, unlike cmp.Compare which orders NaN first.
+func compareFloats(left, right float64) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	case left == right:
+		return 0
+	}
+	return unordered
+}
+
+// compareObjects orders numbers by value and strings and arrays lexicographically, an array containing NaN may be
+// unordered.
+func compareObjects(left, right object) (int, bool) {
+	switch left := left.(type) {
+	case *objInteger:
+		switch right := right.(type) {
+		case *objInteger:
+			return cmp.Compare(left.value, right.value), true
+		case *objFloat:
+			return compareFloats(float64(left.value), right.value), true
+		}
+	case *objFloat:
+		switch right := right.(type) {
+		case *objInteger:
+			return compareFloats(left.value, float64(right.value)), true
+		case *objFloat:
+			return compareFloats(left.value, right.value), true
+		}
+	case *objString:
+		if right, ok := right.(*objString); ok {
+			return strings.Compare(left.value, right.value), true
+		}
+	case *objArray:
+		right, ok := right.(*objArray)
+		if !ok {
+			return 0, false
+		}
+		for i := 0; i < len(left.elements) && i < len(right.elements); i++ {
+			comparison, ok := compareObjects(left.elements[i], right.elements[i])
+			if !ok || comparison != 0 {
+				return comparison, ok
+			}
+		}
+		return cmp.Compare(len(left.elements), len(right.elements)), true
+	}
+	return 0, false
 }
 
 func evalIfExpression(e *ifExpression, env *environment) object {
@@ -315,9 +744,118 @@ func evalIfExpression(e *ifExpression, env *environment) object {
 	return objectNull
 }
 
+func evalMatchExpression(e *matchExpression, env *environment) object {
+	value := Eval(e.value, env)
+	if _, ok := value.(*objError); ok {
+		return value
+	}
+	for _, arm := range e.arms {
+		armEnv := newScopedEnvironment(env, arm.layout)
+		mismatch, err := bindPattern(arm.pattern, value, armEnv, false)
+		if err != nil {
+			return err
+		}
+		if mismatch == "" {
+			return evalBlockStatement(arm.body, armEnv)
+		}
+	}
+	return newError(e.token, "no match arm matched value %v", value)
+}
+
+// bindPattern matches the value against the pattern and declares the identifiers it binds in env.
+// A value that does not fit the pattern is described by the returned mismatch.
+func bindPattern(pat pattern, value object, env *environment, constant bool) (string, *objError) {
+	switch pat := pat.(type) {
+	case *wildcardPattern:
+		return "", nil
+	case *bindingPattern:
+		if !env.declare(pat.name.token.Literal, value, constant) {
+			return "", newError(pat.name.token, "identifier '%v' already declared", pat.name.token.Literal)
+		}
+		return "", nil
+	case *literalPattern:
+		expected := Eval(pat.value, env)
+		if err, ok := expected.(*objError); ok {
+			return "", err
+		}
+		if !objectsEqual(expected, value) {
+			return fmt.Sprintf("expected %v, got %v", pat, value), nil
+		}
+		return "", nil
+	case *arrayPattern:
+		array, ok := value.(*objArray)
+		if !ok {
+			return fmt.Sprintf("expected %v, got %v", ARRAY_OBJ, value.objectType()), nil
+		}
+		if pat.rest == nil && len(array.elements) != len(pat.elements) {
+			return fmt.Sprintf("expected %v elements, got %v", len(pat.elements), len(array.elements)), nil
+		}
+		if pat.rest != nil && len(array.elements) < len(pat.elements) {
+			return fmt.Sprintf("expected at least %v elements, got %v", len(pat.elements), len(array.elements)), nil
+		}
+		for i := range pat.elements {
+			if mismatch, err := bindPattern(pat.elements[i], array.elements[i], env, constant); mismatch != "" || err != nil {
+				return mismatch, err
+			}
+		}
+		if pat.rest != nil {
+			rest := append(make([]object, 0, len(array.elements)-len(pat.elements)), array.elements[len(pat.elements):]...)
+			return bindPattern(pat.rest, &objArray{elements: rest}, env, constant)
+		}
+		return "", nil
+	case *mapPattern:
+		var lookup func(key string) (object, bool)
+		switch value := value.(type) {
+		case *objMap:
+			lookup = value.get
+		case *objStruct:
+			lookup = value.field
+		default:
+			return fmt.Sprintf("expected %v or struct, got %v", MAP_OBJ, value.objectType()), nil
+		}
+		for i := range pat.keys {
+			field, ok := lookup(pat.keys[i].Literal)
+			if !ok {
+				return fmt.Sprintf("missing key '%v'", pat.keys[i].Literal), nil
+			}
+			if mismatch, err := bindPattern(pat.values[i], field, env, constant); mismatch != "" || err != nil {
+				return mismatch, err
+			}
+		}
+		return "", nil
+	case *alternativePattern:
+		mismatches := make([]string, 0, len(pat.alternatives))
+		for i := range pat.alternatives {
+			alternativeEnv := newEnclosedEnvironment(env)
+			mismatch, err := bindPattern(pat.alternatives[i], value, alternativeEnv, constant)
+			if err != nil {
+				return "", err
+			}
+			if mismatch == "" {
+				return bindPattern(pat.alternatives[i], value, env, constant)
+			}
+			mismatches = append(mismatches, mismatch)
+		}
+		return strings.Join(mismatches, " or "), nil
+	}
+	return "", nil
+}
+
 func evalExpressions(expressions []expression, env *environment) ([]object, bool) {
 	result := make([]object, 0, len(expressions))
 	for i := range expressions {
+		if spread, ok := expressions[i].(*spreadExpression); ok {
+			evaluated := Eval(spread.value, env)
+			if _, ok := evaluated.(*objError); ok {
+				return []object{evaluated}, false
+			}
+			array, ok := evaluated.(*objArray)
+			if !ok {
+				return []object{newError(spread.token, "cannot spread %v", evaluated.objectType())}, false
+			}
+			result = append(result, array.elements...)
+			continue
+		}
 		evaluated := Eval(expressions[i], env)
 		if _, ok := evaluated.(*objError); ok {
 			return []object{evaluated}, false
@@ -327,32 +865,212 @@ func evalExpressions(expressions []expression, env *environment) ([]object, bool
 	return result, true
 }
 
-func applyFunction(token Token, o object, args []object) object {
+func evalArguments(arguments []expression, env *environment) ([]object, map[string]object, *objError) {
+	positional := len(arguments)
+	for i := range arguments {
+		if _, ok := arguments[i].(*namedArgument); ok {
+			positional = i
+			break
+		}
+	}
+	args, ok := evalExpressions(arguments[:positional], env)
+	if !ok {
+		return nil, nil, args[0].(*objError)
+	}
+	if positional == len(arguments) {
+		return args, nil, nil
+	}
+	named := make(map[string]object, len(arguments)-positional)
+	for i := positional; i < len(arguments); i++ {
+		argument := arguments[i].(*namedArgument)
+		if _, ok := named[argument.name.token.Literal]; ok {
+			return nil, nil, newError(argument.name.token, "duplicate argument '%v'", argument.name.token.Literal)
+		}
+		value := Eval(argument.value, env)
+		if err, ok := value.(*objError); ok {
+			return nil, nil, err
+		}
+		named[argument.name.token.Literal] = value
+	}
+	return args, named, nil
+}
+
+func evalMapLiteral(e *mapLiteral, env *environment) object {
+	m := newMap()
+	for i := range e.keys {
+		key := Eval(e.keys[i], env)
+		if _, ok := key.(*objError); ok {
+			return key
+		}
+		value := Eval(e.values[i], env)
+		if _, ok := value.(*objError); ok {
+			return value
+		}
+		m.set(key.(*objString).value, value)
+	}
+	return m
+}
+
+// applyFunction calls the function, caller is the environment of the call site.
+func applyFunction(token Token, o object, args []object, named map[string]object, caller *environment) object {
 	switch function := o.(type) {
 	case *objFunction:
-		if len(args) != len(function.parameters) {
-			return &objError{message: fmt.Sprintf("line %v col %v: wrong number of arguments", token.LineNumber, token.ColNumber)}
+		// the call is traced like its caller and draws from its random numbers, even if the function was created,
+		// e.g. restored, in another environment
+		env := newScopedEnvironment(function.env, function.layout)
+		env.tracer = caller.tracer
+		env.random = caller.random
+		if caller.tracer != nil {
+			env.frame = newFrame(function, token, caller.frame)
+			if returned := caller.tracer.call(env.frame); returned != nil {
+				defer returned()
+			}
 		}
-		env := newEnclosedEnvironment(function.env)
-		for i := range function.parameters {
-			env.set(function.parameters[i].token.Literal, args[i])
+		if err := bindArguments(token, function, args, named, env); err != nil {
+			return err
 		}
-		evaluated := Eval(function.body, env)
+		if function.generator {
+			return newGenerator(function, env)
+		}
+		evaluated := evalBlockStatement(function.body, env)
 		if returnValue, ok := evaluated.(*objReturn); ok {
 			return returnValue.value
 		}
 		return evaluated
 	case *objBuiltin:
+		if len(named) != 0 {
+			return newError(token, "built-in function does not accept named arguments")
+		}
+		if function.contextual != nil {
+			return function.contextual(token, caller, args...)
+		}
 		return function.function(token, args...)
+	case *objStructType:
+		if len(args) > len(function.fields) {
+			return newError(token, "wrong number of arguments to '%v': expected %v, got %v", function.name, len(function.fields), len(args)+len(named))
+		}
+		for _, name := range sortedKeys(named) {
+			if !slices.Contains(function.fields, name) {
+				return newError(token, "%v has no field '%v'", function.name, name)
+			}
+		}
+		instance := &objStruct{definition: function, fields: make(map[string]object, len(function.fields))}
+		for i, name := range function.fields {
+			value, ok := named[name]
+			if i < len(args) {
+				if ok {
+					return newError(token, "multiple values for argument '%v'", name)
+				}
+				value, ok = args[i], true
+			}
+			if !ok {
+				return newError(token, "wrong number of arguments to '%v': expected %v, got %v", function.name, len(function.fields), len(args)+len(named))
+			}
+			instance.fields[name] = value
+		}
+		return instance
 	}
-	return &objError{message: fmt.Sprintf("line %v col %v: '%v' is not a function", token.LineNumber, token.ColNumber, o.objectType())}
+	return newError(token, "'%v' is not a function", o.objectType())
+}
+
+// sortedKeys returns the names of the named arguments in order, calls without named arguments do not allocate.
+func sortedKeys(named map[string]object) []string {
+	if len(named) == 0 {
+		return nil
+	}
+	return slices.Sorted(maps.Keys(named))
+}
+
+// bindArguments declares the parameters of the function in env, falling back to named arguments and default values.
+func bindArguments(token Token, function *objFunction, args []object, named map[string]object, env *environment) *objError {
+	var required, positional int
+	var variadic bool
+	for _, param := range function.parameters {
+		switch {
+		case param.variadic:
+			variadic = true
+		case param.defaultValue == nil:
+			required++
+			positional++
+		default:
+			positional++
+		}
+	}
+	arityError := func() *objError {
+		expected := fmt.Sprintf("%v to %v", required, positional)
+		switch {
+		case variadic:
+			expected = fmt.Sprintf("at least %v", required)
+		case required == positional:
+			expected = fmt.Sprint(required)
+		}
+		if function.name == "" {
+			return newError(token, "wrong number of arguments: expected %v, got %v", expected, len(args)+len(named))
+		}
+		return newError(token, "wrong number of arguments to '%v': expected %v, got %v", function.name, expected, len(args)+len(named))
+	}
+	if len(args) > positional && !variadic {
+		return arityError()
+	}
+	for _, name := range sortedKeys(named) {
+		if !slices.ContainsFunc(function.parameters, func(param *parameter) bool {
+			identifier, ok := param.name()
+			return ok && !param.variadic && identifier.token.Literal == name
+		}) {
+			return newError(token, "unknown argument '%v'", name)
+		}
+	}
+
+	for i, param := range function.parameters {
+		var value object
+		var ok bool
+		if identifier, isIdentifier := param.name(); isIdentifier {
+			value, ok = named[identifier.token.Literal]
+		}
+		switch {
+		case param.variadic:
+			rest := make([]object, 0)
+			if i < len(args) {
+				rest = append(rest, args[i:]...)
+			}
+			value = &objArray{elements: rest}
+		case i < len(args):
+			if ok {
+				return newError(token, "multiple values for argument '%v'", param.target)
+			}
+			value = args[i]
+		case ok:
+		case param.defaultValue != nil:
+			value = Eval(param.defaultValue, env)
+			if err, ok := value.(*objError); ok {
+				return err
+			}
+		default:
+			return arityError()
+		}
+		mismatch, err := bindPattern(param.target, value, env, false)
+		if err != nil {
+			return err
+		}
+		if mismatch != "" {
+			return newError(token, "cannot destructure argument %v into %v: %v", i+1, param.target, mismatch)
+		}
+	}
+	return nil
 }
 
 func evalIndexExpression(token Token, left, right object) object {
-	if left.objectType() == ARRAY && right.objectType() == INTEGER {
+	if kind(left) == ARRAY_OBJ && kind(right) == INTEGER_OBJ {
 		return evalArrayIndexExpression(token, left, right)
 	}
-	return &objError{message: fmt.Sprintf("line %v col %v: unsupported index operation: %v", token.LineNumber, token.ColNumber, left.objectType())}
+	if kind(left) == MAP_OBJ && kind(right) == STRING_OBJ {
+		value, ok := left.(*objMap).get(right.(*objString).value)
+		if !ok {
+			return objectNull
+		}
+		return value
+	}
+	return newError(token, "unsupported index operation: %v", left.objectType())
 }
 
 func evalArrayIndexExpression(token Token, left, right object) object {
@@ -363,7 +1081,96 @@ func evalArrayIndexExpression(token Token, left, right object) object {
 		index = count + index
 	}
 	if index < 0 || index >= count {
-		return &objError{message: fmt.Sprintf("line %v col %v: index '%v' is out of bounds", token.LineNumber, token.ColNumber, index)}
+		return newError(token, "index '%v' is out of bounds", index)
 	}
 	return elements[index]
 }
+
+func evalMethodDeclaration(e *functionExpression, env *environment) object {
+	receiverType := evalIdentifier(e.receiverType.token, env)
+	if _, ok := receiverType.(*objError); ok {
+		return receiverType
+	}
+	definition, ok := receiverType.(*objStructType)
+	if !ok {
+		return newError(e.receiverType.token, "'%v' is not a struct", e.receiverType.token.Literal)
+	}
+	function := &objFunction{name: definition.name + "." + e.name.token.Literal, generator: e.generator, body: e.body, parameters: e.parameters, env: env, layout: e.layout}
+	definition.setMethod(e.name.token.Literal, &structMethod{receiver: e.receiver.token.Literal, layout: e.receiverLayout, function: function})
+	return objectNull
+}
+
+func evalMemberExpression(e *memberExpression, left object) object {
+	name := e.member.token.Literal
+	switch left := left.(type) {
+	case *objStruct:
+		if value, ok := left.field(name); ok {
+			return value
+		}
+		if method, ok := left.definition.method(name); ok {
+			return bindMethod(method, left)
+		}
+		return newError(e.member.token, "%v has no field or method '%v'", left.definition.name, name)
+	case *objMap:
+		if value, ok := left.get(name); ok {
+			return value
+		}
+		return objectNull
+	case *objGenerator:
+		return left.member(e.member.token, name)
+	case *objRegex:
+		return left.member(e.member.token, name)
+	}
+	return newError(e.token, "unsupported member access: %v", left.objectType())
+}
+
+// bindMethod returns the method as a function whose environment binds the receiver.
+func bindMethod(method *structMethod, receiver *objStruct) *objFunction {
+	env := newScopedEnvironment(method.function.env, method.layout)
+	env.set(method.receiver, receiver)
+	return &objFunction{name: method.function.name, generator: method.function.generator, body: method.function.body, parameters: method.function.parameters, env: env, layout: method.function.layout}
+}
+
+func evalAssignExpression(e *assignExpression, env *environment) object {
+	if target, ok := e.target.(*identifier); ok {
+		value := Eval(e.value, env)
+		if _, ok := value.(*objError); ok {
+			return value
+		}
+		assign := env.assign
+		if target.resolved {
+			assign = env.ancestor(target.depth).assign
+		}
+		switch err := assign(target.token.Literal, value); {
+		case errors.Is(err, errConstant):
+			return newError(target.token, "cannot assign to constant '%v'", target.token.Literal)
+		case errors.Is(err, errUndeclared):
+			return newError(target.token, "identifier '%v' not found", target.token.Literal)
+		}
+		return value
+	}
+	target, ok := e.target.(*memberExpression)
+	if !ok {
+		return newError(e.token, "invalid assignment target: %v", e.target.String())
+	}
+	left := Eval(target.left, env)
+	if _, ok := left.(*objError); ok {
+		return left
+	}
+	value := Eval(e.value, env)
+	if _, ok := value.(*objError); ok {
+		return value
+	}
+	name := target.member.token.Literal
+	switch left := left.(type) {
+	case *objStruct:
+		if !left.setField(name, value) {
+			return newError(target.member.token, "%v has no field '%v'", left.definition.name, name)
+		}
+		return value
+	case *objMap:
+		left.set(name, value)
+		return value
+	}
+	return newError(target.token, "unsupported member assignment: %v", left.objectType())
+}
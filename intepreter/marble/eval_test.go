@@ -1,38 +1,178 @@
 package marble_test
 
 import (
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	eval "github.com/o-richard/intepreter/marble"
 )
 
+var evalTests = []struct {
+	name, input, output string
+	success             bool
+}{
+	{name: "var statement", input: "var foo = 1;", success: true},
+	{name: "return statement", input: "var foo = 6 * 7; return foo + 2; 6;", output: "44", success: true},
+	{name: "missing identifier", input: "var foo = 6; return bar; 7;", output: "identifier 'bar' not found"},
+	{name: "negate prefix operator", input: "var foo = 6 * 7; !foo;", output: "false", success: true},
+	{name: "minux prefix operator", input: "var foo = 6.7 + 8.3; var bar = -2; -foo;", output: "-15", success: true},
+	{name: "invalid prefix operator", input: "var foo = -true", output: "unknown operator:"},
+	{name: "comparison operators", input: `!((("foo" == "bar") != (" " + "bar")) == true)`, output: "false", success: true},
+	{name: "string equality", input: `var foo = "bar"; foo == "bar"`, output: "true", success: true},
+	{name: "equality operator", input: "[] == [];", output: "true", success: true},
+	{name: "structural equality", input: `[[1, 2.0, "a", [true]] == [1, 2, "a", [true]], [1, 2] != [1, 2, 3], {a: [1]} == {a: [1]}, {a: 1, b: 2} == {b: 2, a: 1}, [1] == "1"]`, output: "[true, true, true, true, false]", success: true},
+	{name: "string ordering", input: `["abc" < "abd", "b" > "abc", "a" <= "a", "" >= "a"]`, output: "[true, true, true, false]", success: true},
+	{name: "array ordering", input: `[[1, 2] < [1, 3], [1, 2] < [1, 2, 0], [2] > [1, 9], [["b"]] >= [["a"], 1], [] <= []]`, output: "[true, true, true, true, true]", success: true},
+	{name: "invalid array ordering", input: `[1, true] < [1, false]`, output: "unable to compare elements"},
+	{name: "identity comparison", input: `var foo = [1]; var bar = foo; [same(foo, bar), same(foo, [1]), foo == [1]]`, output: "[true, false, true]", success: true},
+	{name: "division by zero (integer)", input: "var foo = (7 * 8 + 8) / 0;", output: "invalid division by zero"},
+	{name: "division by zero (float)", input: "var foo = (7 * 8 + 8.8) / 0.0;", output: "invalid division by zero"},
+	{name: "nested statements", input: "var foo = 6 * 7; if (true) { if (true) { return 10; } } 6;", output: "10", success: true},
+	{name: "if statements", input: "var bar = 3; var foo = if (true) { if (false) { return 10; } else { bar + 6; 5 } } foo + bar;", output: "8", success: true},
+	{name: "wrong argument count (custom function)", input: "var add = func() {true};add(1, 2.0)", output: "wrong number of arguments"},
+	{name: "wrong argument count (named function)", input: "var add = func(x, y) { x + y }; add(1)", output: "wrong number of arguments to 'add': expected 2, got 1"},
+	{name: "wrong argument count (default parameters)", input: "var add = func(x, y = 1) { x + y }; add(1, 2, 3)", output: "expected 1 to 2, got 3"},
+	{name: "wrong argument count (variadic parameters)", input: "func(x, ...rest) { x }()", output: "wrong number of arguments: expected at least 1, got 0"},
+	{name: "default parameters", input: "var add = func(x, y = 10, z = x + y) { [x, y, z] }; [add(1), add(1, 2), add(1, 2, 3)]", output: "[[1, 10, 11], [1, 2, 3], [1, 2, 3]]", success: true},
+	{name: "variadic parameters", input: "var f = func(first, ...rest) { [first, rest, len(rest)] }; [f(1), f(1, 2, 3)]", output: "[[1, [], 0], [1, [2, 3], 2]]", success: true},
+	{name: "spread arguments", input: "var f = func(x, y, ...rest) { [x, y, rest] }; var foo = [2, 3, 4]; [f(1, ...foo), f(...foo), [0, ...foo, ...[]]]", output: "[[1, 2, [3, 4]], [2, 3, [4]], [0, 2, 3, 4]]", success: true},
+	{name: "invalid spread", input: "len(...1)", output: "cannot spread INTEGER"},
+	{name: "named arguments", input: "var f = func(x, y = 2, z = 3) { [x, y, z] }; [f(1, z: 4), f(z: 5, x: 6)]", output: "[[1, 2, 4], [6, 2, 5]]", success: true},
+	{name: "unknown named argument", input: "func(x) { x }(x: 1, y: 2)", output: "unknown argument 'y'"},
+	{name: "repeated named argument", input: "func(x) { x }(1, x: 2)", output: "multiple values for argument 'x'"},
+	{name: "named struct fields", input: "struct Point { x, y } Point(y: 2, x: 1)", output: "Point{x: 1, y: 2}", success: true},
+	{name: "named built in arguments", input: "len(x: [])", output: "does not accept named arguments"},
+	{name: "arrow functions", input: "var twice = (f, x) => f(f(x)); var add = (x, y = 1) => { return x + y; }; [twice((x) => x * 3, 2), add(1), (() => 5)()]", output: "[18, 2, 5]", success: true},
+	{name: "NaN comparisons", input: `var nan = float("nan"); [nan == nan, nan != nan, [nan] == [nan], [nan] != [nan], [nan] < [1], [nan] >= [1], [1, nan] > [1, 2], {a: nan} == {a: nan}]`, output: "[false, true, false, true, false, false, false, false]", success: true},
+	{name: "pipeline into curried call", input: "var curry = (x, y) => (z) => [x, y, z]; 1 |> curry(2)(3)", output: "[1, 2, 3]", success: true},
+	{name: "chained pipeline after arrow function", input: "var add = (x, y) => x + y; 3 |> (x) => x * 2 |> add(1) |> (x) => [x]", output: "[7]", success: true},
+	{name: "pipeline operator", input: "var double = (x) => x * 2; var add = (x, y) => x + y; [1, 2] |> len |> double |> add(10)", output: "14", success: true},
+	{name: "invalid function", input: "true(1, 2.0)", output: "not a function"},
+	{name: "array indexing", input: "func () {[1, 2.3, true, [false]]}()[3][-1]", output: "false", success: true},
+	{name: "out of bounds array indexing", input: "[][0]", output: "out of bounds"},
+	{name: "unsupported indexing", input: "true[false]", output: "unsupported index operation:"},
+	{name: "map indexing", input: `var foo = {"bar": 1, baz: [2]}; [foo["bar"], foo["baz"][0], foo["missing"], len(foo)]`, output: "[1, 2, null, 2]", success: true},
+	{name: "type names", input: `[type(1), type(1.5), type(true), type(""), type([]), type({}), type(print()), type(func(){}), type(len)]`, output: "[INTEGER, FLOAT, BOOLEAN, STRING, ARRAY, MAP, NULL, FUNCTION, BUILTIN]", success: true},
+	{name: "type conversions", input: `[int("12"), int(-3.9), int(true), float("2.5"), float(2) / 4, str(12) + str([1]), bool("false"), bool(0), bool(0.5), bool(print())]`, output: "[12, -3, 1, 2.5, 0.5, 12[1], false, false, true, false]", success: true},
+	{name: "invalid integer conversion", input: `int("12a")`, output: "could not parse '12a' as integer"},
+	{name: "invalid float conversion", input: `float("1.2.3")`, output: "could not parse '1.2.3' as float"},
+	{name: "invalid boolean conversion", input: `bool([])`, output: "could not convert ARRAY to BOOLEAN"},
+	{name: "type predicates", input: `[is_integer(1), is_number(1.5), is_string(1), is_array([]), is_map({}), is_null(print()), is_function(len), is_function(func(){}), is_boolean(1)]`, output: "[true, true, false, true, true, true, true, true, false]", success: true},
+	{name: "math functions", input: `[abs(-3), abs(-2.5), floor(2.7), ceil(2.1), round(-2.5), floor(4), sqrt(16), pow(2, 10), pow(2, -1), pow(2.0, 0.5) == sqrt(2), min(3, 1.5, 2), max([1, 7, 3]), sin(0), cos(0), log(1)]`, output: "[3, 2.5, 2, 3, -3, 4, 4, 1024, 0.5, true, 1.5, 7, 0, 1, 0]", success: true},
+	{name: "math domain error", input: "sqrt(-1)", output: "argument -1 out of domain"},
+	{name: "empty extremum", input: "min([])", output: "wrong number of arguments"},
+	{name: "seeded random numbers", input: "seed(7); var a = [random(), random(10), random(-5, 5)]; seed(7); var b = [random(), random(10), random(-5, 5)]; [a == b, a[0] >= 0, a[0] < 1, a[1] >= 0, a[1] < 10, a[2] >= -5, a[2] <= 5]", output: "[true, true, true, true, true, true, true]", success: true},
+	{name: "invalid random range", input: "random(5, 1)", output: "invalid range 5 to 1"},
+	{name: "time functions", input: `var t = time_parse("2024-02-29T12:30:00Z"); [t, time_format(t), time_format(t + duration("1h30m"), "2006-01-02 15:04"), time_parse("01/03/2024", "01/02/2006"), duration("250ms"), now() > t]`, output: "[1709209800000, 2024-02-29T12:30:00.000Z, 2024-02-29 14:00, 1704240000000, 250, true]", success: true},
+	{name: "sleep", input: "var start = now(); sleep(5); now() - start >= 5", output: "true", success: true},
+	{name: "invalid time", input: `time_parse("yesterday")`, output: "could not parse 'yesterday' as time"},
+	{name: "invalid duration", input: `duration("soon")`, output: "could not parse 'soon' as duration"},
+	{name: "regex match", input: `var email = /(\w+)@(\w+)\.com/i; [email.match("Mail: Foo@Example.com"), email.match("none"), /(a)|(b)/.match("b"), regex("x+").match("axxb")]`, output: "[[Foo@Example.com, Foo, Example], null, [b, null, b], [xx]]", success: true},
+	{name: "regex functions", input: `var digits = /(\d)(\d)?/; [find_all(digits, "a1b23"), digits.find_all("none"), replace_all(/(\w+)=(\w+)/, "a=1, b=2", "$2=$1"), split_re("\d+", "a1b22c"), /,\s*/.split_re("x, y,z")]`, output: "[[[1, 1, null], [23, 2, 3]], [], 1=a, 2=b, [a, b, c], [x, y, z]]", success: true},
+	{name: "regex match function", input: `[match_re("(\w+)@(\w+)", "mail: me@host"), match_re(/^\d+$/, "a1"), /a(b)?/.match_re("xa")]`, output: "[[me@host, me, host], null, [a, null]]", success: true},
+	{name: "keyword member names", input: `var m = {}; m.match = 1; m.if = 2; [m.match, m.if, m]`, output: "[1, 2, {match: 1, if: 2}]", success: true},
+	{name: "regex flags", input: `[regex("^B", "i").match("b"), /a.b/s.match("axb"), /x/ims]`, output: "[[b], [axb], /x/ims]", success: true},
+	{name: "regex equality", input: `[/a+/i == regex("a+", "i"), /a/ == /b/, type(/a/)]`, output: "[true, false, REGEX]", success: true},
+	{name: "invalid regex", input: `regex("a(")`, output: "line 1 col 6: invalid regular expression: error parsing regexp: missing closing )"},
+	{name: "unknown regex method", input: `/a/.test("a")`, output: "regular expression has no method 'test'"},
+	{name: "null literal", input: "var x = null; [x, type(null), is_null(x), x == null, null == false, !null]", output: "[null, NULL, true, true, false, true]", success: true},
+	{name: "null coalescing", input: `var config = {"port": 0}; [config.port ?? 80, config.host ?? "localhost", null ?? null ?? 3, false ?? 1]`, output: "[0, localhost, 3, false]", success: true},
+	{name: "null coalescing short circuit", input: "var calls = 0; var f = func() { calls = calls + 1; 2 }; [1 ?? f(), null ?? f(), calls]", output: "[1, 2, 1]", success: true},
+	{name: "optional member access", input: `var user = {"address": {"city": "Nairobi"}}; [user?.address?.city, user.manager?.name, user.manager?.name ?? "none", null?.foo]`, output: "[Nairobi, null, none, null]", success: true},
+	{name: "optional index access", input: `var rows = null; [rows?[0], [1, 2]?[1], {"a": 1}?["a"], rows?[missing]]`, output: "[null, 2, 1, null]", success: true},
+	{name: "optional access of non null errors", input: "5?.foo", output: "unsupported member access: INTEGER"},
+	{name: "member access of null", input: "var x = null; x.foo", output: "unsupported member access: NULL"},
+	{name: "null pattern", input: `var describe = (x) => match (x) { null => "nothing", _ => "something" }; [describe(null), describe(0)]`, output: "[nothing, something]", success: true},
+	{name: "array concatenation", input: "var a = [1, 2]; var b = a + [3] + []; [b, a, [] + []]", output: "[[1, 2, 3], [1, 2], []]", success: true},
+	{name: "repetition", input: `["ab" * 3, 2 * "-", [0] * 3, 2 * [1, [2]], "x" * 0, [] * 5]`, output: "[ababab, --, [0, 0, 0], [1, [2], 1, [2]], , []]", success: true},
+	{name: "negative repetition", input: "[1] * -1", output: "invalid repetition count -1"},
+	{name: "too large repetition", input: `"ab" * 9000000000`, output: "repetition of STRING by 9000000000 is too large"},
+	{name: "too large array repetition", input: "[1, 2] * 10000000", output: "repetition of ARRAY by 10000000 is too large"},
+	{name: "largest repetition", input: `len("ab" * 8388608)`, output: "16777216", success: true},
+	{name: "too large string repetition", input: `"ab" * 8388609`, output: "repetition of STRING by 8388609 is too large"},
+	{name: "operator methods", input: "struct Vector { x, y }; func (v Vector) __add__(other) { Vector(v.x + other.x, v.y + other.y) }; func (v Vector) __mul__(k) { Vector(v.x * k, v.y * k) }; func (v Vector) __lt__(other) { v.x * v.x + v.y * v.y < other.x * other.x + other.y * other.y }; var a = Vector(1, 2); [a + Vector(3, 4), a * 3, a < Vector(2, 2), Vector(3, 0) < a]", output: "[Vector{x: 4, y: 6}, Vector{x: 3, y: 6}, true, false]", success: true},
+	{name: "equality operator methods", input: "struct Money { cents }; func (m Money) __eq__(other) { m.cents == other.cents * 100 }; [Money(500) == Money(5), Money(500) != Money(5), Money(5) != Money(5)]", output: "[true, false, true]", success: true},
+	{name: "failing equality operator method", input: "struct Money { cents }; func (m Money) __eq__(other) { m.cents == other.missing }; Money(5) != Money(5)", output: "Money has no field or method 'missing'"},
+	{name: "right operand without operator method", input: "struct Vector { x, y }; func (v Vector) __mul__(k) { Vector(v.x * k, v.y * k) }; 3 * Vector(1, 2)", output: "unknown operator: INTEGER * Vector"},
+	{name: "struct named after a built-in type", input: "struct INTEGER { v }; INTEGER(1) + 1", output: "unknown operator: INTEGER + INTEGER"},
+	{name: "indexing a struct named after a built-in type", input: `struct MAP { v }; MAP(1)["x"]`, output: "unsupported index operation: MAP"},
+	{name: "type predicates of a struct named after a built-in type", input: "struct INTEGER { v }; [is_integer(INTEGER(1)), is_integer(1)]", output: "[false, true]", success: true},
+	{name: "default struct equality", input: "struct Money { cents }; [Money(5) == Money(5), Money(5) != Money(6)]", output: "[true, true]", success: true},
+	{name: "missing operator method", input: "struct Vector { x, y }; Vector(1, 2) - Vector(1, 1)", output: "unknown operator: Vector - Vector"},
+	{name: "operator method error", input: "struct Vector { x, y }; func (v Vector) __add__(other) { v.x + other.z }; Vector(1, 2) + Vector(1, 1)", output: "Vector has no field or method 'z'"},
+	{name: "struct fields", input: "struct Point { x, y } var p = Point(1, [2]); [p.x, p.y[0], type(p), p]", output: "[1, 2, Point, Point{x: 1, y: [2]}]", success: true},
+	{name: "struct field assignment", input: "struct Point { x, y } var p = Point(1, 2); p.x = p.y = 5; p", output: "Point{x: 5, y: 5}", success: true},
+	{name: "struct methods", input: "struct Point { x, y } func (p Point) norm(scale) { (p.x * p.x + p.y * p.y) * scale } func (p Point) move(dx) { p.x = p.x + dx; p } var p = Point(3, 4); [p.norm(2), p.move(1).norm(1), p.x]", output: "[50, 32, 4]", success: true},
+	{name: "struct equality", input: "struct Point { x, y } struct Pair { x, y } [Point(1, 2) == Point(1, 2), Point(1, 2) == Point(2, 1), Point(1, 2) == Pair(1, 2)]", output: "[true, false, false]", success: true},
+	{name: "wrong argument count (struct constructor)", input: "struct Point { x, y } Point(1)", output: "wrong number of arguments"},
+	{name: "unknown struct field", input: "struct Point { x, y } Point(1, 2).z", output: "Point has no field or method 'z'"},
+	{name: "unknown struct field assignment", input: "struct Point { x, y } var p = Point(1, 2); p.z = 1", output: "Point has no field 'z'"},
+	{name: "method on non struct", input: "var Point = 1; func (p Point) norm() { p }", output: "'Point' is not a struct"},
+	{name: "map members", input: "var foo = {bar: 1}; foo.baz = foo.bar + 1; [foo.baz, foo.missing, foo]", output: "[2, null, {bar: 1, baz: 2}]", success: true},
+	{name: "unsupported member access", input: "[1].foo", output: "unsupported member access: ARRAY"},
+	{name: "reassignment", input: "var foo = 1; var bar = func() { foo = foo + 1 }; bar(); bar(); foo", output: "3", success: true},
+	{name: "undeclared reassignment", input: "foo = 1", output: "identifier 'foo' not found"},
+	{name: "const declaration", input: "const foo = [1]; foo[0]", output: "1", success: true},
+	{name: "const reassignment", input: "const foo = 1; if (true) { foo = 2; }", output: "cannot assign to constant 'foo'"},
+	{name: "const redeclaration", input: "const foo = 1; var foo = 2;", output: "identifier 'foo' already declared"},
+	{name: "var redeclaration", input: "var foo = 1; var foo = 2;", output: "identifier 'foo' already declared"},
+	{name: "parameter redeclaration", input: "func(foo) { var foo = 2; }(1)", output: "identifier 'foo' already declared"},
+	{name: "block scoping", input: "var foo = 1; if (true) { var foo = 2; var bar = 3; } foo", output: "1", success: true},
+	{name: "block scoped identifier", input: "if (true) { var bar = 3; } bar", output: "identifier 'bar' not found"},
+	{name: "block shadowing const", input: "const foo = 1; var bar = if (true) { const foo = 2; foo } else { 0 }; foo + bar", output: "3", success: true},
+	{name: "match literals", input: `var f = func(x) { match (x) { 1 => "one", "a" | "b" => "letter", -1.5 => "negative", true => { return "true"; } _ => "other" } }; [f(1), f("b"), f(-1.5), f(true), f(1.0), f([])]`, output: "[one, letter, negative, true, one, other]", success: true},
+	{name: "match array patterns", input: `var f = func(x) { match (x) { [] => 0, [a] => a, [a, [b, _]] => a + b, [a, b] | [a, b, _] => a * b } }; [f([]), f([5]), f([1, [2, 3]]), f([2, 3]), f([2, 4, 6])]`, output: "[0, 5, 3, 6, 8]", success: true},
+	{name: "match bindings are scoped", input: `var a = 1; match ([2]) { [a] => a } + a`, output: "3", success: true},
+	{name: "match without matching arm", input: `match (3) { 1 | 2 => 1, [x] => x }`, output: "no match arm matched value 3"},
+	{name: "match with repeated binding", input: `match ([1, 2]) { [x, x] => x }`, output: "identifier 'x' already declared"},
+	{name: "array destructuring", input: "var [a, [b, _], ...rest] = [1, [2, 3], 4, 5]; [a, b, rest]", output: "[1, 2, [4, 5]]", success: true},
+	{name: "map destructuring", input: `struct Person { name, address } var {name, address: {city: town}} = Person("marble", {city: "nairobi"}); const {age} = {age: 3}; [name, town, age]`, output: "[marble, nairobi, 3]", success: true},
+	{name: "const destructuring", input: "const [a, b] = [1, 2]; a = 3", output: "cannot assign to constant 'a'"},
+	{name: "destructuring length mismatch", input: "var [a, b] = [1, 2, 3];", output: "cannot destructure ARRAY into [a, b]: expected 2 elements, got 3"},
+	{name: "destructuring type mismatch", input: "var [a, ...rest] = {a: 1};", output: "cannot destructure MAP into [a, ...rest]: expected ARRAY, got MAP"},
+	{name: "destructuring missing key", input: "var {a, b} = {a: 1};", output: "missing key 'b'"},
+	{name: "destructuring parameters", input: "var f = func([a, b], {c} = {c: 10}) { a + b + c }; [f([1, 2]), f([1, 2], {c: 3})]", output: "[13, 6]", success: true},
+	{name: "destructuring parameter mismatch", input: "func([a, b]) { a }([1])", output: "cannot destructure argument 1 into [a, b]: expected 2 elements, got 1"},
+	{name: "match map patterns", input: `match ({kind: "circle", radius: 2}) { {kind: "square", side} => side * side, {kind: "circle", radius: r} => 3 * r * r }`, output: "12", success: true},
+	{name: "generator iteration", input: `var numbers = func(n) { yield n; yield n + 1; return "end"; }; var g = numbers(1); [g, g.next(), g.next(), g.next(), g.next()]`, output: "[generator numbers, {value: 1, done: false}, {value: 2, done: false}, {value: end, done: true}, {value: null, done: true}]", success: true},
+	{name: "generator collection", input: "var letters = (...values) => { yield values[0]; yield ...values; }; collect(letters(1, 2, 3))", output: "[1, 1, 2, 3]", success: true},
+	{name: "infinite generator", input: "var naturals = func(n) { yield n; yield ...naturals(n + 1); }; var g = naturals(1); var take = func(n) { if (n == 0) { return []; }; [g.next().value, ...take(n - 1)] }; [take(5), g.close(), g.next()]", output: "[[1, 2, 3, 4, 5], null, {value: null, done: true}]", success: true},
+	{name: "unstarted generator close", input: "var numbers = func() { yield 1; }; var g = numbers(); g.close(); [g.next(), collect(g)]", output: "[{value: null, done: true}, []]", success: true},
+	{name: "generator resuming itself", input: "var numbers = func() { yield 1; yield self.next(); }; var self = numbers(); [self.next(), self.next()]", output: "generator already running"},
+	{name: "generator closing itself", input: "var numbers = func() { self.close(); yield 1; }; var self = numbers(); self.next()", output: "generator already running"},
+	{name: "generator error", input: "var broken = func() { yield 1; yield missing; }; collect(broken())", output: "identifier 'missing' not found"},
+	{name: "invalid yield delegation", input: "var broken = func() { yield ...1; }; broken().next()", output: "cannot yield from INTEGER"},
+	{name: "spawned tasks", input: "var square = func(x) { x * x }; var task = spawn square(4); [task, wait(task), wait([spawn square(2), spawn square(3)])]", output: "[task square, 16, [4, 9]]", success: true},
+	{name: "spawned task error", input: "var broken = func() { missing }; wait(spawn broken())", output: "identifier 'missing' not found"},
+	{name: "shared maps and structs", input: "struct Counter { n }; var counter = Counter(0); var totals = {}; var add = func(n) { totals.last = n; counter.n = n; func (c Counter) value() { c.n }; [totals, counter, totals == {last: n}, counter.value(), json_stringify(totals)]; n }; var tasks = [spawn add(1), spawn add(2), spawn add(3), spawn add(4)]; [wait(tasks), len(totals), counter.n > 0]", output: "[[1, 2, 3, 4], 1, true]", success: true},
+	{name: "channels", input: "var c = channel(); var producer = func(n) { if (n > 0) { send(c, n); producer(n - 1); } else { close(c); } }; spawn producer(3); [recv(c), recv(c), recv(c), recv(c)]", output: "[3, 2, 1, null]", success: true},
+	{name: "buffered channel", input: "var c = channel(2); send(c, 1); send(c, 2); [c, recv(c), recv(c)]", output: "[channel(2), 1, 2]", success: true},
+	{name: "recv without running tasks", input: "var c = channel(); recv(c)", output: "line 1 col 24: recv on an empty channel without running tasks would block forever"},
+	{name: "send without running tasks", input: "var c = channel(1); send(c, 1); send(c, 2)", output: "line 1 col 37: send on a full channel without running tasks would block forever"},
+	{name: "recv of a completed task", input: "var c = channel(1); wait(spawn send(c, 1)); [recv(c), recv(c)]", output: "line 1 col 59: recv on an empty channel without running tasks"},
+	{name: "send on closed channel", input: "var c = channel(1); close(c); send(c, 1)", output: "send on closed channel"},
+	{name: "close of closed channel", input: "var c = channel(); close(c); close(c)", output: "close of closed channel"},
+	{name: "passing assertions", input: `[assert(1 < 2), assert(true, "message"), assert_eq([1, {a: 2}], [1, {a: 2}])]`, output: "[null, null, null]", success: true},
+	{name: "failing assertion", input: "assert(1 > 2)", output: "line 1 col 7: assertion failed"},
+	{name: "failing assertion with message", input: `assert({}.missing, "missing value")`, output: "assertion failed: missing value"},
+	{name: "failing equality assertion", input: "assert_eq(1 + 1, 3)", output: "line 1 col 10: assertion failed: 2 != 3"},
+	{name: "resolved shadowing", input: "var x = 1; var f = func() { var y = x; var x = 2; [y, x] }; f()", output: "[1, 2]", success: true},
+	{name: "resolved closure over a later declaration", input: "var x = 1; var g = func() { var f = func() { x }; var x = 2; f() }; g()", output: "2", success: true},
+	{name: "resolved forward reference", input: "var f = func() { g() }; var g = func() { 3 }; f()", output: "3", success: true},
+	{name: "resolved built in shadowing", input: "var f = func() { len([1]) }; var a = f(); var len = func(x) { 0 }; [a, f()]", output: "[1, 0]", success: true},
+	{name: "resolved method scopes", input: "struct Box { value }; var scale = 10; func (b Box) add(x) { if (true) { var y = x; b.value * scale + y } }; Box(2).add(1)", output: "21", success: true},
+	{name: "resolved assignment", input: "var count = 0; var increment = func() { if (true) { count = count + 1; } }; increment(); increment(); count", output: "2", success: true},
+	{name: "slot closures capture their own call", input: "var counter = func() { var n = 0; func() { n = n + 1; n } }; var a = counter(); var b = counter(); a(); a(); [a(), b()]", output: "[3, 1]", success: true},
+	{name: "slot layout beyond inline slots", input: "var f = func(a, b, c) { var d = a + b; var e = c * 2; var g = d + e; [a, b, c, d, e, g] }; f(1, 2, 3)", output: "[1, 2, 3, 3, 6, 9]", success: true},
+	{name: "slot destructured parameters", input: "var f = func([a, b], {c}) { a + b + c }; f([1, 2], {\"c\": 3})", output: "6", success: true},
+	{name: "slot recursive calls", input: "var sum = func(n) { var rest = if (n == 0) { 0 } else { sum(n - 1) }; n + rest }; sum(10)", output: "55", success: true},
+	{name: "built in functions", input: "var foo = push([], 1, 2.0, false, [true]); len(foo);", output: "4", success: true},
+}
+
 func TestEval(t *testing.T) {
-	tests := []struct {
-		name, input, output string
-		success             bool
-	}{
-		{name: "var statement", input: "var foo = 1;", success: true},
-		{name: "return statement", input: "var foo = 6 * 7; return foo + 2; 6;", output: "44", success: true},
-		{name: "missing identifier", input: "var foo = 6; return bar; 7;", output: "identifier 'bar' not found"},
-		{name: "negate prefix operator", input: "var foo = 6 * 7; !foo;", output: "false", success: true},
-		{name: "minux prefix operator", input: "var foo = 6.7 + 8.3; var bar = -2; -foo;", output: "-15", success: true},
-		{name: "invalid prefix operator", input: "var foo = -true", output: "unknown operator:"},
-		{name: "comparison operators", input: `!((("foo" == "bar") != (" " + "bar")) == true)`, output: "false", success: true},
-		{name: "string equality", input: `var foo = "bar"; foo == "bar"`, output: "true", success: true},
-		{name: "equality operator", input: "[] == [];", output: "false", success: true},
-		{name: "division by zero (integer)", input: "var foo = (7 * 8 + 8) / 0;", output: "invalid division by zero"},
-		{name: "division by zero (float)", input: "var foo = (7 * 8 + 8.8) / 0.0;", output: "invalid division by zero"},
-		{name: "nested statements", input: "var foo = 6 * 7; if (true) { if (true) { return 10; } } 6;", output: "10", success: true},
-		{name: "if statements", input: "var bar = 3; var foo = if (true) { if (false) { return 10; } else { bar + 6; 5 } } foo + bar;", output: "8", success: true},
-		{name: "wrong argument count (custom function)", input: "var add = func() {true};add(1, 2.0)", output: "wrong number of arguments"},
-		{name: "invalid function", input: "true(1, 2.0)", output: "not a function"},
-		{name: "array indexing", input: "func () {[1, 2.3, true, [false]]}()[3][-1]", output: "false", success: true},
-		{name: "out of bounds array indexing", input: "[][0]", output: "out of bounds"},
-		{name: "unsupported indexing", input: "true[false]", output: "unsupported index operation:"},
-		{name: "built in functions", input: "var foo = push([], 1, 2.0, false, [true]); len(foo);", output: "4", success: true},
-	}
-	for _, test := range tests {
+	for _, test := range evalTests {
 		t.Run(test.name, func(t *testing.T) {
 			l := eval.NewLexer([]byte(test.input))
 			p := eval.NewParser(l)
@@ -55,3 +195,48 @@ func TestEval(t *testing.T) {
 		})
 	}
 }
+
+// TestGeneratorClose checks that closing a generator paused in a chain of delegations stops every goroutine of the chain.
+func TestGeneratorClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	input := "var naturals = func(n) { yield n; yield ...naturals(n + 1); }; var g = naturals(1); var take = func(n) { if (n == 0) { return []; }; [g.next().value, ...take(n - 1)] }; [take(50), g.close()]"
+	evaluated := eval.Eval(eval.NewParser(eval.NewLexer([]byte(input))).ParseProgram(), eval.NewEnvironment())
+	if evaluated == nil || strings.HasPrefix(evaluated.String(), "line") {
+		t.Fatalf("unexpected output, got=%v", evaluated)
+	}
+	for deadline := time.Now().Add(5 * time.Second); runtime.NumGoroutine() > before; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected goroutines, got=%v want=%v", runtime.NumGoroutine(), before)
+		}
+	}
+}
+
+// TestGeneratorDropped checks that the goroutines of generators dropped before they are exhausted are stopped once the
+// generators are collected.
+func TestGeneratorDropped(t *testing.T) {
+	before := runtime.NumGoroutine()
+	input := "var naturals = func(n) { yield n; yield ...naturals(n + 1); }; var first = func() { var g = naturals(1); [g.next().value, g.next().value, g.next().value] }; first()"
+	evaluated := eval.Eval(eval.NewParser(eval.NewLexer([]byte(input))).ParseProgram(), eval.NewEnvironment())
+	if evaluated == nil || evaluated.String() != "[1, 2, 3]" {
+		t.Fatalf("unexpected output, got=%v want=[1, 2, 3]", evaluated)
+	}
+	for deadline := time.Now().Add(5 * time.Second); runtime.NumGoroutine() > before; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected goroutines, got=%v want=%v", runtime.NumGoroutine(), before)
+		}
+		runtime.GC()
+	}
+}
+
+// TestRandomPerEnvironment checks that seeding the random number generator of one environment leaves the values of
+// another unchanged.
+func TestRandomPerEnvironment(t *testing.T) {
+	first, second, fresh := eval.NewEnvironment(), eval.NewEnvironment(), eval.NewEnvironment()
+	eval.Eval(eval.NewParser(eval.NewLexer([]byte("seed(7); random(1000000)"))).ParseProgram(), first)
+	eval.Eval(eval.NewParser(eval.NewLexer([]byte("seed(8); random(1000000)"))).ParseProgram(), second)
+	actualOutput := eval.Eval(eval.NewParser(eval.NewLexer([]byte("random(1000000)"))).ParseProgram(), first).String()
+	expected := eval.Eval(eval.NewParser(eval.NewLexer([]byte("seed(7); random(1000000); random(1000000)"))).ParseProgram(), fresh).String()
+	if actualOutput != expected {
+		t.Fatalf("unexpected output, got=%v want=%v", actualOutput, expected)
+	}
+}
@@ -0,0 +1,208 @@
+package marble
+
+import (
+	"runtime"
+	"sync"
+)
+
+type generatorStep struct {
+	value object
+	done  bool
+}
+
+// objGenerator runs the body of a generator function on a goroutine that is paused after every yield. The goroutine
+// only refers to the state of the generator, so a generator that is dropped before it is exhausted or closed is
+// collected and closed by its finalizer.
+type objGenerator struct {
+	name string
+	*generatorState
+}
+
+// generatorState is the part of a generator shared with the goroutine running its body.
+type generatorState struct {
+	evaluate func() object // evaluates the body of the generator function
+
+	mu      sync.Mutex
+	started bool
+	running bool // the body runs until its next yield
+	done    bool
+	steps   chan generatorStep
+	resume  chan struct{}
+	cancel  chan struct{} // closed by close to stop the paused body
+}
+
+func newGenerator(function *objFunction, env *environment) *objGenerator {
+	state := &generatorState{steps: make(chan generatorStep), resume: make(chan struct{}), cancel: make(chan struct{})}
+	state.evaluate = func() object { return evalBlockStatement(function.body, env) }
+	env.generator = state
+	g := &objGenerator{name: function.name, generatorState: state}
+	runtime.SetFinalizer(g, func(g *objGenerator) { g.close() })
+	return g
+}
+
+func (o *objGenerator) objectType() string { return GENERATOR_OBJ }
+
+func (o *objGenerator) String() string {
+	if o.name == "" {
+		return "generator"
+	}
+	return "generator " + o.name
+}
+
+func (o *generatorState) run() {
+	var result object = objectNull
+	switch evaluated := o.evaluate().(type) {
+	case *objReturn:
+		result = evaluated.value
+	case *objError:
+		result = evaluated
+	}
+	o.steps <- generatorStep{value: result, done: true}
+}
+
+// next resumes the generator until its next yield, the step is done once the body finishes. Resuming a running
+// generator, e.g. from its own body, is an error.
+func (o *generatorState) next(token Token) generatorStep {
+	o.mu.Lock()
+	if o.running {
+		o.mu.Unlock()
+		return generatorStep{value: newError(token, "generator already running")}
+	}
+	if o.done {
+		o.mu.Unlock()
+		return generatorStep{value: objectNull, done: true}
+	}
+	o.running = true
+	if o.started {
+		o.mu.Unlock()
+		o.resume <- struct{}{}
+	} else {
+		o.started = true
+		o.mu.Unlock()
+		go o.run()
+	}
+	step := <-o.steps
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.running = false
+	o.done = step.done
+	return step
+}
+
+// close stops the body of the generator, further steps are done. The goroutine of a paused body exits from its
+// yield, closing any generator it delegates to. Closing a running generator is an error.
+func (o *generatorState) close() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.running {
+		return false
+	}
+	if o.done {
+		return true
+	}
+	o.done = true
+	if o.started {
+		close(o.cancel)
+	}
+	return true
+}
+
+// yield hands the value to next and pauses the body until the generator is resumed or closed. The body only runs
+// while next waits for its step, so the value is always received.
+func (o *generatorState) yield(value object) {
+	o.steps <- generatorStep{value: value}
+	select {
+	case <-o.resume:
+	case <-o.cancel:
+		runtime.Goexit()
+	}
+}
+
+func (o *objGenerator) member(token Token, name string) object {
+	switch name {
+	case "next":
+	case "close":
+		return &objBuiltin{
+			function: func(token Token, args ...object) object {
+				if len(args) != 0 {
+					return newError(token, "wrong number of arguments")
+				}
+				if !o.close() {
+					return newError(token, "generator already running")
+				}
+				return objectNull
+			},
+		}
+	default:
+		return newError(token, "%v has no field or method '%v'", GENERATOR_OBJ, name)
+	}
+	return &objBuiltin{
+		function: func(token Token, args ...object) object {
+			if len(args) != 0 {
+				return newError(token, "wrong number of arguments")
+			}
+			step := o.next(token)
+			if _, ok := step.value.(*objError); ok {
+				return step.value
+			}
+			result := newMap()
+			result.set("value", step.value)
+			result.set("done", evalBoolean(step.done))
+			return result
+		},
+	}
+}
+
+func evalYieldExpression(e *yieldExpression, value object, env *environment) object {
+	var generator *generatorState
+	for ; env != nil && generator == nil; env = env.outer {
+		generator = env.generator
+	}
+	if generator == nil {
+		return newError(e.token, "yield outside of a generator")
+	}
+	if !e.delegate {
+		generator.yield(value)
+		return objectNull
+	}
+
+	switch value := value.(type) {
+	case *objArray:
+		for i := range value.elements {
+			generator.yield(value.elements[i])
+		}
+		return objectNull
+	case *objGenerator:
+		// the delegated generator is closed when this generator is closed while paused in it
+		defer value.close()
+		for {
+			step := value.next(e.token)
+			if _, ok := step.value.(*objError); ok || step.done {
+				return step.value
+			}
+			generator.yield(step.value)
+		}
+	}
+	return newError(e.token, "cannot yield from %v", value.objectType())
+}
+
+func builtinCollect(token Token, args ...object) object {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	generator, ok := args[0].(*objGenerator)
+	if !ok {
+		return newError(token, "invalid argument type: %v", args[0].objectType())
+	}
+	elements := make([]object, 0)
+	for {
+		step := generator.next(token)
+		if _, ok := step.value.(*objError); ok {
+			return step.value
+		}
+		if step.done {
+			return &objArray{elements: elements}
+		}
+		elements = append(elements, step.value)
+	}
+}
@@ -0,0 +1,189 @@
+package marble
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func builtinJSONParse(token Token, args ...object) object {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	input, ok := args[0].(*objString)
+	if !ok {
+		return newError(token, "invalid argument type: %v", args[0].objectType())
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(input.value))
+	decoder.UseNumber()
+	value, err := decodeJSON(decoder)
+	if err == nil {
+		if _, err = decoder.Token(); errors.Is(err, io.EOF) {
+			err = nil
+		} else if err == nil {
+			err = errors.New("unexpected value after top-level value")
+		}
+	}
+	if err != nil {
+		offset := decoder.InputOffset()
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		}
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return newError(token, "invalid JSON at offset %v: %v", offset, err)
+	}
+	return value
+}
+
+func decodeJSON(decoder *json.Decoder) (object, error) {
+	t, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := t.(type) {
+	case json.Delim:
+		if t == '[' {
+			elements := make([]object, 0)
+			for decoder.More() {
+				element, err := decodeJSON(decoder)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, element)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, err
+			}
+			return &objArray{elements: elements}, nil
+		}
+		m := newMap()
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			name, _ := key.(string)
+			m.set(name, value)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case json.Number:
+		if !strings.ContainsAny(t.String(), ".eE") {
+			if value, err := strconv.ParseInt(t.String(), 10, 64); err == nil {
+				return newInteger(value), nil
+			}
+		}
+		value, err := strconv.ParseFloat(t.String(), 64)
+		if err != nil {
+			return nil, err
+		}
+		return &objFloat{value: value}, nil
+	case string:
+		return &objString{value: t}, nil
+	case bool:
+		return evalBoolean(t), nil
+	}
+	return objectNull, nil
+}
+
+func builtinJSONStringify(token Token, args ...object) object {
+	if maxArgs := 2; len(args) == 0 || len(args) > maxArgs {
+		return newError(token, "wrong number of arguments")
+	}
+
+	var indent string
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *objInteger:
+			indent = strings.Repeat(" ", int(max(arg.value, 0)))
+		case *objString:
+			indent = arg.value
+		default:
+			return newError(token, "invalid argument type: %v", args[1].objectType())
+		}
+	}
+
+	var compact bytes.Buffer
+	if err := encodeJSON(&compact, args[0]); err != nil {
+		return newError(token, "unable to encode JSON: %v", err)
+	}
+	if indent == "" {
+		return &objString{value: compact.String()}
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, compact.Bytes(), "", indent); err != nil {
+		return newError(token, "unable to encode JSON: %v", err)
+	}
+	return &objString{value: indented.String()}
+}
+
+func encodeJSON(output *bytes.Buffer, o object) error {
+	switch o := o.(type) {
+	case *objNull:
+		_, _ = output.WriteString("null")
+	case *objBoolean:
+		_, _ = output.WriteString(strconv.FormatBool(o.value))
+	case *objInteger:
+		_, _ = output.WriteString(strconv.FormatInt(o.value, 10))
+	case *objFloat:
+		if math.IsNaN(o.value) || math.IsInf(o.value, 0) {
+			return errors.New("unsupported float value: " + o.String())
+		}
+		value := strconv.FormatFloat(o.value, 'g', -1, 64)
+		if !strings.ContainsAny(value, ".eE") {
+			value += ".0"
+		}
+		_, _ = output.WriteString(value)
+	case *objString:
+		encodeJSONString(output, o.value)
+	case *objArray:
+		_ = output.WriteByte('[')
+		for i := range o.elements {
+			if i > 0 {
+				_ = output.WriteByte(',')
+			}
+			if err := encodeJSON(output, o.elements[i]); err != nil {
+				return err
+			}
+		}
+		_ = output.WriteByte(']')
+	case *objMap:
+		_ = output.WriteByte('{')
+		keys, values := o.entries()
+		for i, key := range keys {
+			if i > 0 {
+				_ = output.WriteByte(',')
+			}
+			encodeJSONString(output, key)
+			_ = output.WriteByte(':')
+			if err := encodeJSON(output, values[i]); err != nil {
+				return err
+			}
+		}
+		_ = output.WriteByte('}')
+	default:
+		return errors.New("unsupported value type: " + o.objectType())
+	}
+	return nil
+}
+
+func encodeJSONString(output *bytes.Buffer, value string) {
+	encoder := json.NewEncoder(output)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+	output.Truncate(output.Len() - 1) // trailing newline written by Encode
+}
@@ -0,0 +1,49 @@
+package marble_test
+
+import (
+	"strings"
+	"testing"
+
+	eval "github.com/o-richard/intepreter/marble"
+)
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name, input, output string
+		success             bool
+	}{
+		{name: "parse scalars", input: `[json_parse("1"), json_parse("1.5"), json_parse("1e2"), json_parse("true"), json_parse("null"), json_parse(json_stringify("foo"))]`, output: "[1, 1.5, 100, true, null, foo]", success: true},
+		{name: "parse integer and float types", input: `var foo = json_parse("[1, 1.0]"); [foo[0] / 2, foo[1] / 2]`, output: "[0, 0.5]", success: true},
+		{name: "parse nested values", input: `var foo = json_parse(json_stringify({name: "marble", tags: [1, {ok: false}]}, 4)); [foo["name"], foo["tags"][-1]["ok"], len(foo)]`, output: "[marble, false, 2]", success: true},
+		{name: "parse malformed input", input: `json_parse("[1, 2,, 3]")`, output: "invalid JSON at offset 7"},
+		{name: "parse truncated input", input: `json_parse("[1, ")`, output: "invalid JSON at offset 4: unexpected end of JSON input"},
+		{name: "parse empty input", input: `json_parse("")`, output: "invalid JSON at offset 0: unexpected EOF"},
+		{name: "parse trailing input", input: `json_parse("[1] [2]")`, output: "invalid JSON at offset"},
+		{name: "stringify values", input: `json_stringify([1, 2.0, "a<b", true, {name: "marble", list: []}])`, output: `[1,2.0,"a<b",true,{"name":"marble","list":[]}]`, success: true},
+		{name: "stringify with indentation", input: `json_stringify({foo: [1]}, 2)`, output: "{\n  \"foo\": [\n    1\n  ]\n}", success: true},
+		{name: "stringify round trip", input: `json_stringify(json_parse(json_stringify({b: 1, a: [json_parse("null"), 1.5]})))`, output: `{"b":1,"a":[null,1.5]}`, success: true},
+		{name: "stringify unsupported value", input: `json_stringify([func(){}])`, output: "unsupported value type: FUNCTION"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := eval.NewLexer([]byte(test.input))
+			p := eval.NewParser(l)
+			program := p.ParseProgram()
+			actualErrors := p.Errors()
+			if len(actualErrors) != 0 {
+				t.Fatalf("unexpected errors: %v", actualErrors)
+			}
+			evaluated := eval.Eval(program, eval.NewEnvironment())
+			var actuatlOutput string
+			if evaluated != nil {
+				actuatlOutput = evaluated.String()
+			}
+			if test.success && actuatlOutput != test.output {
+				t.Fatalf("unexpected output, got=%v want=%v", actuatlOutput, test.output)
+			}
+			if !test.success && !strings.Contains(actuatlOutput, test.output) {
+				t.Fatalf("unexpected output, got=%v want=%v", actuatlOutput, test.output)
+			}
+		})
+	}
+}
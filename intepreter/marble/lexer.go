@@ -10,6 +10,8 @@ type lexer struct {
 
 	currentLineNumber int
 	currentColNumber  int
+
+	previous TokenType // type of the last token, a slash after the end of an operand is a division
 }
 
 func NewLexer(input []byte) *lexer {
@@ -20,12 +22,23 @@ func NewLexer(input []byte) *lexer {
 }
 
 func (l *lexer) NextToken() Token {
+	tok := l.readToken()
+	l.previous = tok.Type
+	return tok
+}
+
+func (l *lexer) readToken() Token {
 	l.skipWhitespaceAndComments()
 
 	var tok Token
 	switch l.currentByte {
 	case '=':
-		tok = l.readOperator(l.currentByte, ASSIGN, EQ)
+		if l.peekNextByte() == '>' {
+			tok = l.newToken(ARROW, "=>")
+			l.readByte()
+		} else {
+			tok = l.readOperator(l.currentByte, ASSIGN, EQ)
+		}
 	case '+':
 		tok = l.newToken(ADD, "+")
 	case '-':
@@ -33,6 +46,9 @@ func (l *lexer) NextToken() Token {
 	case '*':
 		tok = l.newToken(MULTIPLY, "*")
 	case '/':
+		if !l.operandEnded() {
+			return l.readRegex()
+		}
 		tok = l.newToken(DIVIDE, "/")
 	case '!':
 		tok = l.readOperator(l.currentByte, NEGATE, NOTEQ)
@@ -40,10 +56,41 @@ func (l *lexer) NextToken() Token {
 		tok = l.readOperator(l.currentByte, LT, LTE)
 	case '>':
 		tok = l.readOperator(l.currentByte, GT, GTE)
+	case '|':
+		if l.peekNextByte() == '>' {
+			tok = l.newToken(PIPE, "|>")
+			l.readByte()
+		} else {
+			tok = l.newToken(BAR, "|")
+		}
+	case '?':
+		switch l.peekNextByte() {
+		case '?':
+			tok = l.newToken(COALESCE, "??")
+			l.readByte()
+		case '.':
+			tok = l.newToken(OPTIONAL_DOT, "?.")
+			l.readByte()
+		case '[':
+			tok = l.newToken(OPTIONAL_LBRACKET, "?[")
+			l.readByte()
+		default:
+			tok = l.newToken(ILLEGAL, "?")
+		}
 	case ',':
 		tok = l.newToken(COMMA, ",")
 	case ';':
 		tok = l.newToken(SEMICOLON, ";")
+	case ':':
+		tok = l.newToken(COLON, ":")
+	case '.':
+		if l.peekNextByte() == '.' && l.nextIndex+1 < len(l.input) && l.input[l.nextIndex+1] == '.' {
+			tok = l.newToken(ELLIPSIS, "...")
+			l.readByte()
+			l.readByte()
+		} else {
+			tok = l.newToken(DOT, ".")
+		}
 	case '(':
 		tok = l.newToken(LPAREN, "(")
 	case ')':
@@ -160,27 +207,83 @@ func (l *lexer) readIdentifier() Token {
 		l.readByte()
 	}
 	literal := string(l.input[currentIndex:l.currentIndex])
+	return Token{Type: lookupIdentifier(literal), Literal: literal, LineNumber: lineNumber, ColNumber: colNumber}
+}
 
+// isKeyword reports whether the token is a keyword, keywords are valid member names, e.g. re.match(s).
+func isKeyword(t Token) bool {
+	return t.Type != IDENTIFIER && lookupIdentifier(t.Literal) == t.Type
+}
+
+func lookupIdentifier(literal string) TokenType {
 	var tokentype TokenType
 	switch literal {
 	case "func":
 		tokentype = FUNCTION
 	case "var":
 		tokentype = VARIABLE
+	case "const":
+		tokentype = CONSTANT
 	case "true":
 		tokentype = TRUE
 	case "false":
 		tokentype = FALSE
+	case "null":
+		tokentype = NULL
 	case "if":
 		tokentype = IF
 	case "else":
 		tokentype = ELSE
 	case "return":
 		tokentype = RETURN
+	case "struct":
+		tokentype = STRUCT
+	case "match":
+		tokentype = MATCH
+	case "yield":
+		tokentype = YIELD
+	case "spawn":
+		tokentype = SPAWN
 	default:
 		tokentype = IDENTIFIER
 	}
-	return Token{Type: tokentype, Literal: literal, LineNumber: lineNumber, ColNumber: colNumber}
+	return tokentype
+}
+
+// operandEnded reports whether the last token ends an operand, e.g. the identifier in a / b.
+func (l *lexer) operandEnded() bool {
+	switch l.previous {
+	case IDENTIFIER, INTEGER, FLOAT, STRING, REGEX, TRUE, FALSE, NULL, RPAREN, RBRACKET, RBRACE:
+		return true
+	}
+	return false
+}
+
+// readRegex reads a regular expression literal such as /a+b/i, a slash is escaped with a backslash. The literal of an
+// unterminated regular expression has no closing slash.
+func (l *lexer) readRegex() Token {
+	currentIndex := l.currentIndex
+	lineNumber := l.currentLineNumber + 1
+	colNumber := l.currentColNumber
+
+	for {
+		l.readByte()
+		if l.currentByte == '\\' && l.peekNextByte() != '\n' && l.peekNextByte() != 0 {
+			l.readByte()
+			continue
+		}
+		if l.currentByte == 0 || l.currentByte == '\n' {
+			return Token{Type: REGEX, Literal: string(l.input[currentIndex:l.currentIndex]), LineNumber: lineNumber, ColNumber: colNumber}
+		}
+		if l.currentByte == '/' {
+			break
+		}
+	}
+	l.readByte()
+	for validIdentifierByte(l.currentByte) {
+		l.readByte()
+	}
+	return Token{Type: REGEX, Literal: string(l.input[currentIndex:l.currentIndex]), LineNumber: lineNumber, ColNumber: colNumber}
 }
 
 func validNumberDigit(char byte) bool {
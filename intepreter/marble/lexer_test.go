@@ -31,7 +31,9 @@ if (5 < 10) {
 	return false;
 }
 [1, 2, 3];
-55;`
+55;
+x / 2 / [/a\/b/i, /c/];
+a ?? b?.c?[0] ?? null;`
 	expected := []lexer.Token{
 		{Type: lexer.VARIABLE, Literal: "var", LineNumber: 1, ColNumber: 1},
 		{Type: lexer.IDENTIFIER, Literal: "five", LineNumber: 1, ColNumber: 5},
@@ -143,7 +145,28 @@ if (5 < 10) {
 		{Type: lexer.SEMICOLON, Literal: ";", LineNumber: 24, ColNumber: 10},
 		{Type: lexer.INTEGER, Literal: "55", LineNumber: 25, ColNumber: 1},
 		{Type: lexer.SEMICOLON, Literal: ";", LineNumber: 25, ColNumber: 3},
-		{Type: lexer.EOF, Literal: "", LineNumber: 25, ColNumber: 4},
+		{Type: lexer.IDENTIFIER, Literal: "x", LineNumber: 26, ColNumber: 1},
+		{Type: lexer.DIVIDE, Literal: "/", LineNumber: 26, ColNumber: 3},
+		{Type: lexer.INTEGER, Literal: "2", LineNumber: 26, ColNumber: 5},
+		{Type: lexer.DIVIDE, Literal: "/", LineNumber: 26, ColNumber: 7},
+		{Type: lexer.LBRACKET, Literal: "[", LineNumber: 26, ColNumber: 9},
+		{Type: lexer.REGEX, Literal: `/a\/b/i`, LineNumber: 26, ColNumber: 10},
+		{Type: lexer.COMMA, Literal: ",", LineNumber: 26, ColNumber: 17},
+		{Type: lexer.REGEX, Literal: "/c/", LineNumber: 26, ColNumber: 19},
+		{Type: lexer.RBRACKET, Literal: "]", LineNumber: 26, ColNumber: 22},
+		{Type: lexer.SEMICOLON, Literal: ";", LineNumber: 26, ColNumber: 23},
+		{Type: lexer.IDENTIFIER, Literal: "a", LineNumber: 27, ColNumber: 1},
+		{Type: lexer.COALESCE, Literal: "??", LineNumber: 27, ColNumber: 3},
+		{Type: lexer.IDENTIFIER, Literal: "b", LineNumber: 27, ColNumber: 6},
+		{Type: lexer.OPTIONAL_DOT, Literal: "?.", LineNumber: 27, ColNumber: 7},
+		{Type: lexer.IDENTIFIER, Literal: "c", LineNumber: 27, ColNumber: 9},
+		{Type: lexer.OPTIONAL_LBRACKET, Literal: "?[", LineNumber: 27, ColNumber: 10},
+		{Type: lexer.INTEGER, Literal: "0", LineNumber: 27, ColNumber: 12},
+		{Type: lexer.RBRACKET, Literal: "]", LineNumber: 27, ColNumber: 13},
+		{Type: lexer.COALESCE, Literal: "??", LineNumber: 27, ColNumber: 15},
+		{Type: lexer.NULL, Literal: "null", LineNumber: 27, ColNumber: 18},
+		{Type: lexer.SEMICOLON, Literal: ";", LineNumber: 27, ColNumber: 22},
+		{Type: lexer.EOF, Literal: "", LineNumber: 27, ColNumber: 23},
 	}
 
 	l := lexer.NewLexer([]byte(input))
@@ -0,0 +1,193 @@
+package marble
+
+import (
+	"math"
+	"math/rand/v2"
+	"sync"
+)
+
+// randomSource is the random number generator of a top-level environment, shared with the environments enclosed by
+// it and the functions called from them. seed makes the values it returns deterministic.
+type randomSource struct {
+	mu        sync.Mutex
+	generator *rand.Rand
+}
+
+func newRandomSource() *randomSource {
+	return &randomSource{generator: rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))}
+}
+
+func (r *randomSource) seed(seed uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generator = rand.New(rand.NewPCG(seed, seed))
+}
+
+// number returns the value of an integer or a float.
+func number(o object) (float64, bool) {
+	switch o := o.(type) {
+	case *objInteger:
+		return float64(o.value), true
+	case *objFloat:
+		return o.value, true
+	}
+	return 0, false
+}
+
+func builtinAbs(token Token, args ...object) object {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	switch arg := args[0].(type) {
+	case *objInteger:
+		if arg.value < 0 {
+			return newInteger(-arg.value)
+		}
+		return arg
+	case *objFloat:
+		return &objFloat{value: math.Abs(arg.value)}
+	}
+	return newError(token, "invalid argument type: %v", args[0].objectType())
+}
+
+// roundingFunction rounds a float to an integer, integers are returned unchanged.
+func roundingFunction(round func(float64) float64) *objBuiltin {
+	return &objBuiltin{
+		function: func(token Token, args ...object) object {
+			if len(args) != 1 {
+				return newError(token, "wrong number of arguments")
+			}
+			switch arg := args[0].(type) {
+			case *objInteger:
+				return arg
+			case *objFloat:
+				value := round(arg.value)
+				if math.IsNaN(value) || math.IsInf(value, 0) || value >= math.MaxInt64 || value < math.MinInt64 {
+					return newError(token, "could not convert %v to integer", arg.value)
+				}
+				return newInteger(int64(value))
+			}
+			return newError(token, "invalid argument type: %v", args[0].objectType())
+		},
+	}
+}
+
+// floatFunction applies the function to a number, valid reports whether the number is in its domain.
+func floatFunction(function func(float64) float64, valid func(float64) bool) *objBuiltin {
+	return &objBuiltin{
+		function: func(token Token, args ...object) object {
+			if len(args) != 1 {
+				return newError(token, "wrong number of arguments")
+			}
+			value, ok := number(args[0])
+			if !ok {
+				return newError(token, "invalid argument type: %v", args[0].objectType())
+			}
+			if valid != nil && !valid(value) {
+				return newError(token, "argument %v out of domain", args[0].String())
+			}
+			return &objFloat{value: function(value)}
+		},
+	}
+}
+
+// builtinPow raises an integer to a non-negative integer power exactly, any other numbers result in a float.
+func builtinPow(token Token, args ...object) object {
+	if maxArgs := 2; len(args) != maxArgs {
+		return newError(token, "wrong number of arguments")
+	}
+	base, baseOK := args[0].(*objInteger)
+	exponent, exponentOK := args[1].(*objInteger)
+	if baseOK && exponentOK && exponent.value >= 0 {
+		result, factor := int64(1), base.value
+		for e := exponent.value; e > 0; e >>= 1 {
+			if e&1 == 1 {
+				result *= factor
+			}
+			factor *= factor
+		}
+		return newInteger(result)
+	}
+	for i := range args {
+		if _, ok := number(args[i]); !ok {
+			return newError(token, "invalid argument type: %v", args[i].objectType())
+		}
+	}
+	x, _ := number(args[0])
+	y, _ := number(args[1])
+	return &objFloat{value: math.Pow(x, y)}
+}
+
+// extremumFunction returns the number for which better holds against every other one, of the arguments or of the
+// elements of a single array argument.
+func extremumFunction(better func(x, y float64) bool) *objBuiltin {
+	return &objBuiltin{
+		function: func(token Token, args ...object) object {
+			if len(args) == 1 {
+				if array, ok := args[0].(*objArray); ok {
+					args = array.elements
+				}
+			}
+			if len(args) == 0 {
+				return newError(token, "wrong number of arguments")
+			}
+			var extremum object
+			var extremumValue float64
+			for i := range args {
+				value, ok := number(args[i])
+				if !ok {
+					return newError(token, "invalid argument type: %v", args[i].objectType())
+				}
+				if extremum == nil || better(value, extremumValue) {
+					extremum, extremumValue = args[i], value
+				}
+			}
+			return extremum
+		},
+	}
+}
+
+// builtinRandom returns a float in [0, 1) without arguments, an integer in [0, n) with one and an integer in
+// [low, high] with two.
+func builtinRandom(token Token, env *environment, args ...object) object {
+	if maxArgs := 2; len(args) > maxArgs {
+		return newError(token, "wrong number of arguments")
+	}
+	bounds := make([]int64, len(args))
+	for i := range args {
+		bound, ok := args[i].(*objInteger)
+		if !ok {
+			return newError(token, "invalid argument type: %v", args[i].objectType())
+		}
+		bounds[i] = bound.value
+	}
+
+	random := env.random
+	random.mu.Lock()
+	defer random.mu.Unlock()
+	switch len(bounds) {
+	case 0:
+		return &objFloat{value: random.generator.Float64()}
+	case 1:
+		if bounds[0] <= 0 {
+			return newError(token, "invalid upper bound %v", bounds[0])
+		}
+		return newInteger(random.generator.Int64N(bounds[0]))
+	}
+	if bounds[0] > bounds[1] || bounds[1]-bounds[0] < 0 || bounds[1]-bounds[0] == math.MaxInt64 {
+		return newError(token, "invalid range %v to %v", bounds[0], bounds[1])
+	}
+	return newInteger(bounds[0] + random.generator.Int64N(bounds[1]-bounds[0]+1))
+}
+
+func builtinSeed(token Token, env *environment, args ...object) object {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	seed, ok := args[0].(*objInteger)
+	if !ok {
+		return newError(token, "invalid argument type: %v", args[0].objectType())
+	}
+	env.random.seed(uint64(seed.value))
+	return objectNull
+}
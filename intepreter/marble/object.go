@@ -2,11 +2,29 @@ package marble
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
 )
 
 const (
-	ARRAY = "ARRAY"
+	INTEGER_OBJ   = "INTEGER"
+	FLOAT_OBJ     = "FLOAT"
+	BOOLEAN_OBJ   = "BOOLEAN"
+	STRING_OBJ    = "STRING"
+	ARRAY_OBJ     = "ARRAY"
+	MAP_OBJ       = "MAP"
+	NULL_OBJ      = "NULL"
+	RETURN_OBJ    = "RETURN"
+	ERROR_OBJ     = "ERROR"
+	FUNCTION_OBJ  = "FUNCTION"
+	BUILTIN_OBJ   = "BUILTIN"
+	STRUCT_OBJ    = "STRUCT"
+	GENERATOR_OBJ = "GENERATOR"
+	TASK_OBJ      = "TASK"
+	CHANNEL_OBJ   = "CHANNEL"
+	REGEX_OBJ     = "REGEX"
 )
 
 type object interface {
@@ -18,35 +36,56 @@ type objInteger struct {
 	value int64
 }
 
-func (o *objInteger) objectType() string { return INTEGER }
+func (o *objInteger) objectType() string { return INTEGER_OBJ }
 func (o *objInteger) String() string     { return fmt.Sprintf("%v", o.value) }
 
+// Integers in this range are shared instead of allocated by every evaluation.
+const (
+	minCachedInteger = -128
+	maxCachedInteger = 1024
+)
+
+var cachedIntegers = func() []objInteger {
+	integers := make([]objInteger, maxCachedInteger-minCachedInteger+1)
+	for i := range integers {
+		integers[i].value = int64(i + minCachedInteger)
+	}
+	return integers
+}()
+
+func newInteger(value int64) *objInteger {
+	if value >= minCachedInteger && value <= maxCachedInteger {
+		return &cachedIntegers[value-minCachedInteger]
+	}
+	return &objInteger{value: value}
+}
+
 type objFloat struct {
 	value float64
 }
 
-func (o *objFloat) objectType() string { return FLOAT }
+func (o *objFloat) objectType() string { return FLOAT_OBJ }
 func (o *objFloat) String() string     { return fmt.Sprintf("%v", o.value) }
 
 type objBoolean struct {
 	value bool
 }
 
-func (o *objBoolean) objectType() string { return "BOOLEAN" }
+func (o *objBoolean) objectType() string { return BOOLEAN_OBJ }
 func (o *objBoolean) String() string     { return fmt.Sprintf("%v", o.value) }
 
 type objString struct {
 	value string
 }
 
-func (o *objString) objectType() string { return STRING }
+func (o *objString) objectType() string { return STRING_OBJ }
 func (o *objString) String() string     { return o.value }
 
 type objArray struct {
 	elements []object
 }
 
-func (o *objArray) objectType() string { return ARRAY }
+func (o *objArray) objectType() string { return ARRAY_OBJ }
 
 func (o *objArray) String() string {
 	var output strings.Builder
@@ -60,32 +99,107 @@ func (o *objArray) String() string {
 	return output.String()
 }
 
+// objMap is safe for concurrent use, spawned functions share the maps captured by their closures.
+type objMap struct {
+	mu     sync.RWMutex
+	keys   []string
+	values map[string]object
+}
+
+func newMap() *objMap {
+	return &objMap{values: make(map[string]object)}
+}
+
+func (o *objMap) objectType() string { return MAP_OBJ }
+
+func (o *objMap) String() string {
+	var output strings.Builder
+	keys, values := o.entries()
+	pairs := make([]string, len(keys))
+	for i := range keys {
+		pairs[i] = keys[i] + ": " + values[i].String()
+	}
+	_, _ = output.WriteString("{")
+	_, _ = output.WriteString(strings.Join(pairs, ", "))
+	_, _ = output.WriteString("}")
+	return output.String()
+}
+
+func (o *objMap) get(key string) (object, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	value, ok := o.values[key]
+	return value, ok
+}
+
+func (o *objMap) set(key string, value object) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *objMap) len() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return len(o.keys)
+}
+
+// entries returns the keys in insertion order and their values.
+func (o *objMap) entries() ([]string, []object) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	values := make([]object, len(o.keys))
+	for i := range o.keys {
+		values[i] = o.values[o.keys[i]]
+	}
+	return slices.Clone(o.keys), values
+}
+
+// kind returns the type of the object to dispatch on. The type of a struct is its name, which may be any identifier
+// such as INTEGER, so the kind of every struct is STRUCT_OBJ.
+func kind(o object) string {
+	if _, ok := o.(*objStruct); ok {
+		return STRUCT_OBJ
+	}
+	return o.objectType()
+}
+
 type objNull struct{}
 
-func (o *objNull) objectType() string { return "NULL" }
+func (o *objNull) objectType() string { return NULL_OBJ }
 func (o *objNull) String() string     { return "null" }
 
 type objReturn struct {
 	value object
 }
 
-func (o *objReturn) objectType() string { return RETURN }
+func (o *objReturn) objectType() string { return RETURN_OBJ }
 func (o *objReturn) String() string     { return o.value.String() }
 
 type objError struct {
 	message string
 }
 
-func (o *objError) objectType() string { return "ERROR" }
+func (o *objError) objectType() string { return ERROR_OBJ }
 func (o *objError) String() string     { return o.message }
 
+func newError(token Token, format string, a ...any) *objError {
+	return &objError{message: fmt.Sprintf("line %v col %v: %v", token.LineNumber, token.ColNumber, fmt.Sprintf(format, a...))}
+}
+
 type objFunction struct {
-	parameters []*identifier
+	name       string
+	generator  bool
+	parameters []*parameter
 	body       *blockStatement
 	env        *environment
+	layout     *layout // slots of the environment of a call
 }
 
-func (o *objFunction) objectType() string { return FUNCTION }
+func (o *objFunction) objectType() string { return FUNCTION_OBJ }
 
 func (o *objFunction) String() string {
 	var output strings.Builder
@@ -101,8 +215,101 @@ func (o *objFunction) String() string {
 }
 
 type objBuiltin struct {
-	function func(token Token, args ...object) object
+	function   func(token Token, args ...object) object
+	contextual func(token Token, caller *environment, args ...object) object // set instead of function to use the caller
 }
 
-func (o *objBuiltin) objectType() string { return "BUILTIN" }
+func (o *objBuiltin) objectType() string { return BUILTIN_OBJ }
 func (o *objBuiltin) String() string     { return "built-in function" }
+
+type structMethod struct {
+	receiver string
+	layout   *layout // slots of the environment binding the receiver
+	function *objFunction
+}
+
+// objStructType is safe for concurrent use, methods may be declared while spawned functions call them.
+type objStructType struct {
+	name    string
+	fields  []string
+	mu      sync.RWMutex
+	methods map[string]*structMethod
+}
+
+func (o *objStructType) objectType() string { return STRUCT_OBJ }
+
+func (o *objStructType) String() string {
+	return fmt.Sprintf("struct %v {%v}", o.name, strings.Join(o.fields, ", "))
+}
+
+func (o *objStructType) method(name string) (*structMethod, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	method, ok := o.methods[name]
+	return method, ok
+}
+
+func (o *objStructType) setMethod(name string, method *structMethod) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.methods[name] = method
+}
+
+// methodTable returns a copy of the methods.
+func (o *objStructType) methodTable() map[string]*structMethod {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return maps.Clone(o.methods)
+}
+
+// objStruct is safe for concurrent use, spawned functions share the structs captured by their closures.
+type objStruct struct {
+	definition *objStructType
+	mu         sync.RWMutex
+	fields     map[string]object
+}
+
+func (o *objStruct) objectType() string { return o.definition.name }
+
+func (o *objStruct) String() string {
+	var output strings.Builder
+	values := o.fieldValues()
+	fields := make([]string, len(values))
+	for i, name := range o.definition.fields {
+		fields[i] = name + ": " + values[i].String()
+	}
+	_, _ = output.WriteString(o.definition.name)
+	_, _ = output.WriteString("{")
+	_, _ = output.WriteString(strings.Join(fields, ", "))
+	_, _ = output.WriteString("}")
+	return output.String()
+}
+
+func (o *objStruct) field(name string) (object, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	value, ok := o.fields[name]
+	return value, ok
+}
+
+// setField assigns the value to the field, it reports whether the struct has the field.
+func (o *objStruct) setField(name string, value object) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.fields[name]; !ok {
+		return false
+	}
+	o.fields[name] = value
+	return true
+}
+
+// fieldValues returns the values of the fields in the order of the struct definition.
+func (o *objStruct) fieldValues() []object {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	values := make([]object, len(o.definition.fields))
+	for i, name := range o.definition.fields {
+		values[i] = o.fields[name]
+	}
+	return values
+}
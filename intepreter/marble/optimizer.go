@@ -0,0 +1,192 @@
+package marble
+
+import (
+	"strconv"
+	"strings"
+)
+
+// blockExpression evaluates a block in its own scope, it replaces if expressions with a constant condition.
+type blockExpression struct {
+	token Token // IF token of the replaced if expression
+	block *blockStatement
+}
+
+func (e *blockExpression) node()           {}
+func (e *blockExpression) expressionNode() {}
+
+func (e *blockExpression) String() string { return e.block.String() }
+
+// Optimize folds constant arithmetic, string concatenation, comparisons, boolean negation and null coalescing and
+// removes the dead branches of if expressions with a constant condition. Expressions that evaluate to an error, e.g. a division by
+// zero, are left to fail at runtime with their original position.
+func Optimize(p *program) *program {
+	for i := range p.statements {
+		p.statements[i] = optimizeStatement(p.statements[i])
+	}
+	return p
+}
+
+func optimizeStatement(s statement) statement {
+	switch s := s.(type) {
+	case *varStatement:
+		s.value = optimizeExpression(s.value)
+	case *returnStatement:
+		s.value = optimizeExpression(s.value)
+	case *expressionStatement:
+		s.value = optimizeExpression(s.value)
+	case *blockStatement:
+		optimizeBlock(s)
+	}
+	return s
+}
+
+func optimizeBlock(b *blockStatement) {
+	if b == nil {
+		return
+	}
+	for i := range b.statements {
+		b.statements[i] = optimizeStatement(b.statements[i])
+	}
+}
+
+func optimizeExpressions(expressions []expression) {
+	for i := range expressions {
+		expressions[i] = optimizeExpression(expressions[i])
+	}
+}
+
+func optimizeExpression(e expression) expression {
+	switch e := e.(type) {
+	case *arrayLiteral:
+		optimizeExpressions(e.elements)
+	case *mapLiteral:
+		optimizeExpressions(e.values)
+	case *prefixExpression:
+		e.right = optimizeExpression(e.right)
+		if right, ok := constant(e.right); ok {
+			return fold(e.operator, evalPrefixExpression(e.operator, right), e)
+		}
+	case *infixExpression:
+		e.left = optimizeExpression(e.left)
+		e.right = optimizeExpression(e.right)
+		if e.operator.Type == COALESCE {
+			if left, ok := constant(e.left); ok {
+				if left == objectNull {
+					return e.right
+				}
+				return e.left
+			}
+			return e
+		}
+		left, leftOK := constant(e.left)
+		right, rightOK := constant(e.right)
+		if leftOK && rightOK {
+			return fold(constantToken(e.left), evalInfixExpression(e.operator, left, right, nil), e)
+		}
+	case *ifExpression:
+		e.condition = optimizeExpression(e.condition)
+		optimizeBlock(e.consequence)
+		optimizeBlock(e.alternative)
+		if condition, ok := constant(e.condition); ok {
+			if condition != objectFalse && condition != objectNull {
+				return &blockExpression{token: e.token, block: e.consequence}
+			}
+			if e.alternative == nil {
+				return &nullLiteral{token: Token{Type: NULL, Literal: "null", LineNumber: e.token.LineNumber, ColNumber: e.token.ColNumber}}
+			}
+			return &blockExpression{token: e.token, block: e.alternative}
+		}
+	case *functionExpression:
+		for i := range e.parameters {
+			if e.parameters[i].defaultValue != nil {
+				e.parameters[i].defaultValue = optimizeExpression(e.parameters[i].defaultValue)
+			}
+		}
+		optimizeBlock(e.body)
+	case *spreadExpression:
+		e.value = optimizeExpression(e.value)
+	case *namedArgument:
+		e.value = optimizeExpression(e.value)
+	case *callExpression:
+		e.function = optimizeExpression(e.function)
+		optimizeExpressions(e.arguments)
+	case *indexExpression:
+		e.left = optimizeExpression(e.left)
+		e.index = optimizeExpression(e.index)
+	case *memberExpression:
+		e.left = optimizeExpression(e.left)
+	case *assignExpression:
+		e.target = optimizeExpression(e.target)
+		e.value = optimizeExpression(e.value)
+	case *matchExpression:
+		e.value = optimizeExpression(e.value)
+		for i := range e.arms {
+			optimizeBlock(e.arms[i].body)
+		}
+	case *yieldExpression:
+		e.value = optimizeExpression(e.value)
+	case *spawnExpression:
+		e.call.function = optimizeExpression(e.call.function)
+		optimizeExpressions(e.call.arguments)
+	}
+	return e
+}
+
+// constant returns the value of a literal.
+func constant(e expression) (object, bool) {
+	switch e := e.(type) {
+	case *integerLiteral:
+		return newInteger(e.value), true
+	case *floatLiteral:
+		return &objFloat{value: e.value}, true
+	case *stringLiteral:
+		return e.value, true
+	case *booleanLiteral:
+		return evalBoolean(e.value), true
+	case *nullLiteral:
+		return objectNull, true
+	}
+	return nil, false
+}
+
+func constantToken(e expression) Token {
+	switch e := e.(type) {
+	case *integerLiteral:
+		return e.token
+	case *floatLiteral:
+		return e.token
+	case *stringLiteral:
+		return e.token
+	case *booleanLiteral:
+		return e.token
+	case *nullLiteral:
+		return e.token
+	}
+	return Token{}
+}
+
+// fold replaces the original expression with a literal of the value, errors keep the original expression.
+func fold(token Token, value object, original expression) expression {
+	position := Token{LineNumber: token.LineNumber, ColNumber: token.ColNumber}
+	switch value := value.(type) {
+	case *objInteger:
+		position.Type, position.Literal = INTEGER, strconv.FormatInt(value.value, 10)
+		return &integerLiteral{token: position, value: value.value}
+	case *objFloat:
+		position.Type, position.Literal = FLOAT, strconv.FormatFloat(value.value, 'g', -1, 64)
+		if !strings.ContainsAny(position.Literal, ".eEIN") {
+			position.Literal += ".0"
+		}
+		return &floatLiteral{token: position, value: value.value}
+	case *objString:
+		position.Type, position.Literal = STRING, value.value
+		return newStringLiteral(position)
+	case *objBoolean:
+		position.Type, position.Literal = FALSE, "false"
+		if value.value {
+			position.Type, position.Literal = TRUE, "true"
+		}
+		return &booleanLiteral{token: position, value: value.value}
+	}
+	return original
+}
@@ -0,0 +1,46 @@
+package marble_test
+
+import (
+	"testing"
+
+	eval "github.com/o-richard/intepreter/marble"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name, input, optimized, output string
+	}{
+		{name: "integer arithmetic", input: "60 * 60 * 24", optimized: "86400;", output: "86400"},
+		{name: "negation", input: "-(3) + 1", optimized: "-2;", output: "-2"},
+		{name: "float arithmetic", input: "1.5 * 2 + 1", optimized: "4.0;", output: "4"},
+		{name: "string concatenation", input: `"foo" + "bar" + "baz"`, optimized: `"foobarbaz";`, output: "foobarbaz"},
+		{name: "boolean negation", input: "!true == !(1 > 2)", optimized: "false;", output: "false"},
+		{name: "partially constant expression", input: "var f = func(x) { x * (60 * 60) }; f(2)", optimized: "var f = func(x){(x * 3600);};f(2);", output: "7200"},
+		{name: "true branch", input: "if (1 < 2) { 1 } else { 2 }", optimized: "{1;};", output: "1"},
+		{name: "false branch", input: `if ("a" == "b") { 1 } else { 2 }`, optimized: "{2;};", output: "2"},
+		{name: "null coalescing", input: "null ?? 1 + 2; 4 ?? x", optimized: "3;4;", output: "4"},
+		{name: "null condition", input: "if (null) { 1 } else { 2 }", optimized: "{2;};", output: "2"},
+		{name: "false branch without alternative", input: "if (!true) { 1 }", optimized: "null;", output: "null"},
+		{name: "dead branch scoping", input: "var x = 1; if (true) { var x = 2; }; x", optimized: "var x = 1;{var x = 2;};x;", output: "1"},
+		{name: "division by zero", input: "var x = 1;\nx + 10 / (5 - 5)", optimized: "var x = 1;(x + (10 / 0));", output: "line 2 col 8: invalid division by zero"},
+		{name: "type error", input: `"a" + 1`, optimized: `("a" + 1);`, output: "line 1 col 5: unknown operator: STRING + INTEGER"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := eval.NewParser(eval.NewLexer([]byte(test.input)))
+			program := p.ParseProgram()
+			if issues := p.Errors(); len(issues) != 0 {
+				t.Fatalf("unexpected errors: %v", issues)
+			}
+			unoptimized := eval.Eval(program, eval.NewEnvironment())
+			program = eval.Optimize(program)
+			if actualOutput := program.String(); actualOutput != test.optimized {
+				t.Fatalf("unexpected optimized program, got=%v want=%v", actualOutput, test.optimized)
+			}
+			optimized := eval.Eval(program, eval.NewEnvironment())
+			if optimized.String() != test.output || unoptimized.String() != test.output {
+				t.Fatalf("unexpected output, got=%v (unoptimized %v) want=%v", optimized.String(), unoptimized.String(), test.output)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package marble
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Capabilities decides which of the operating system builtins a script may use.
+// Calls that are not allowed evaluate to a permission error.
+type Capabilities struct {
+	Read  []string // paths (and their descendants) readable through read_file and list_dir
+	Write []string // paths (and their descendants) writable through write_file
+	Env   bool
+	Exec  bool
+	Exit  bool
+	Args  []string // values returned by args
+}
+
+// LoadOS binds read_file, write_file, list_dir, env, args, exit and exec into the environment.
+func (e *environment) LoadOS(c Capabilities) {
+	read := resolveAllowedPaths(c.Read)
+	write := resolveAllowedPaths(c.Write)
+	args := make([]object, len(c.Args))
+	for i := range c.Args {
+		args[i] = &objString{value: c.Args[i]}
+	}
+
+	e.set("read_file", &objBuiltin{
+		function: func(token Token, a ...object) object {
+			if len(a) != 1 {
+				return newError(token, "wrong number of arguments")
+			}
+			path, ok := a[0].(*objString)
+			if !ok {
+				return newError(token, "invalid argument type: %v", a[0].objectType())
+			}
+			resolved, ok := pathAllowed(read, path.value)
+			if !ok {
+				return newError(token, "permission denied: read access to '%v'", path.value)
+			}
+			content, err := os.ReadFile(resolved)
+			if err != nil {
+				return newError(token, "unable to read file: %v", err)
+			}
+			return &objString{value: string(content)}
+		},
+	})
+	e.set("write_file", &objBuiltin{
+		function: func(token Token, a ...object) object {
+			if maxArgs := 2; len(a) != maxArgs {
+				return newError(token, "wrong number of arguments")
+			}
+			path, ok := a[0].(*objString)
+			if !ok {
+				return newError(token, "invalid argument type: %v", a[0].objectType())
+			}
+			content, ok := a[1].(*objString)
+			if !ok {
+				return newError(token, "invalid argument type: %v", a[1].objectType())
+			}
+			resolved, ok := pathAllowed(write, path.value)
+			if !ok {
+				return newError(token, "permission denied: write access to '%v'", path.value)
+			}
+			if err := os.WriteFile(resolved, []byte(content.value), 0o600); err != nil {
+				return newError(token, "unable to write file: %v", err)
+			}
+			return objectNull
+		},
+	})
+	e.set("list_dir", &objBuiltin{
+		function: func(token Token, a ...object) object {
+			if len(a) != 1 {
+				return newError(token, "wrong number of arguments")
+			}
+			path, ok := a[0].(*objString)
+			if !ok {
+				return newError(token, "invalid argument type: %v", a[0].objectType())
+			}
+			resolved, ok := pathAllowed(read, path.value)
+			if !ok {
+				return newError(token, "permission denied: read access to '%v'", path.value)
+			}
+			entries, err := os.ReadDir(resolved)
+			if err != nil {
+				return newError(token, "unable to list directory: %v", err)
+			}
+			names := make([]object, len(entries))
+			for i := range entries {
+				names[i] = &objString{value: entries[i].Name()}
+			}
+			return &objArray{elements: names}
+		},
+	})
+	e.set("env", &objBuiltin{
+		function: func(token Token, a ...object) object {
+			if len(a) != 1 {
+				return newError(token, "wrong number of arguments")
+			}
+			key, ok := a[0].(*objString)
+			if !ok {
+				return newError(token, "invalid argument type: %v", a[0].objectType())
+			}
+			if !c.Env {
+				return newError(token, "permission denied: environment access to '%v'", key.value)
+			}
+			value, ok := os.LookupEnv(key.value)
+			if !ok {
+				return objectNull
+			}
+			return &objString{value: value}
+		},
+	})
+	e.set("args", &objBuiltin{
+		function: func(token Token, a ...object) object {
+			if len(a) != 0 {
+				return newError(token, "wrong number of arguments")
+			}
+			return &objArray{elements: append([]object(nil), args...)}
+		},
+	})
+	e.set("exit", &objBuiltin{
+		function: func(token Token, a ...object) object {
+			if len(a) > 1 {
+				return newError(token, "wrong number of arguments")
+			}
+			var code int64
+			if len(a) == 1 {
+				value, ok := a[0].(*objInteger)
+				if !ok {
+					return newError(token, "invalid argument type: %v", a[0].objectType())
+				}
+				code = value.value
+			}
+			if !c.Exit {
+				return newError(token, "permission denied: exit")
+			}
+			os.Exit(int(code))
+			return objectNull
+		},
+	})
+	e.set("exec", &objBuiltin{
+		function: func(token Token, a ...object) object {
+			if len(a) == 0 {
+				return newError(token, "wrong number of arguments")
+			}
+			command := make([]string, len(a))
+			for i := range a {
+				value, ok := a[i].(*objString)
+				if !ok {
+					return newError(token, "invalid argument type: %v", a[i].objectType())
+				}
+				command[i] = value.value
+			}
+			if !c.Exec {
+				return newError(token, "permission denied: exec '%v'", command[0])
+			}
+			var stdout, stderr bytes.Buffer
+			cmd := exec.Command(command[0], command[1:]...) //nolint:gosec // the script was explicitly allowed to execute commands
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			if err := cmd.Run(); err != nil {
+				var exitErr *exec.ExitError
+				if errors.As(err, &exitErr) {
+					return newError(token, "command '%v' failed: %v: %v", command[0], err, strings.TrimSpace(stderr.String()))
+				}
+				return newError(token, "command '%v' failed: %v", command[0], err)
+			}
+			return &objString{value: stdout.String()}
+		},
+	})
+}
+
+func resolveAllowedPaths(paths []string) []string {
+	resolved := make([]string, 0, len(paths))
+	for i := range paths {
+		if path, ok := resolvePath(paths[i]); ok {
+			resolved = append(resolved, path)
+		}
+	}
+	return resolved
+}
+
+// resolvePath returns the absolute path with symbolic links evaluated for the longest existing prefix. The path is not
+// cleaned before symbolic links are evaluated, so link/.. refers to the parent of the target of link like it does for
+// the operating system. Paths through a dangling symbolic link are not resolved, writing to one would create its
+// target.
+func resolvePath(path string) (string, bool) {
+	if !filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", false
+		}
+		path = wd + string(filepath.Separator) + path
+	}
+	var missing []string
+	for {
+		evaluated, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				evaluated = filepath.Join(evaluated, missing[i])
+			}
+			return evaluated, true
+		}
+		if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+			// the path exists but cannot be evaluated, e.g. a dangling symbolic link whose target may be outside
+			return "", false
+		}
+		separator := strings.LastIndexByte(path, filepath.Separator)
+		parent := path[:separator+1]
+		if separator > 0 {
+			parent = path[:separator]
+		}
+		if separator < 0 || parent == path {
+			return "", false
+		}
+		missing = append(missing, path[separator+1:])
+		path = parent
+	}
+}
+
+// pathAllowed returns the resolved path if it is one of the allowed paths or a descendant of one, the caller accesses
+// the resolved path so that the file checked is the file accessed.
+func pathAllowed(allowed []string, path string) (string, bool) {
+	resolved, ok := resolvePath(path)
+	if !ok {
+		return "", false
+	}
+	for i := range allowed {
+		relative, err := filepath.Rel(allowed[i], resolved)
+		if err != nil {
+			continue
+		}
+		if relative == "." || (relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))) {
+			return resolved, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,93 @@
+package marble_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	eval "github.com/o-richard/intepreter/marble"
+)
+
+func TestOSBuiltins(t *testing.T) {
+	allowed := t.TempDir()
+	denied := t.TempDir()
+	if err := os.WriteFile(filepath.Join(allowed, "foo.txt"), []byte("foo"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(allowed, "out"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(denied, "bar.txt"), []byte("bar"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(denied, "nested"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(denied, "nested"), filepath.Join(allowed, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(denied, "created.txt"), filepath.Join(allowed, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MARBLE_TEST", "marble")
+
+	capabilities := eval.Capabilities{Read: []string{allowed}, Write: []string{allowed}, Args: []string{"one", "two"}}
+	tests := []struct {
+		name, input, output string
+		capabilities        eval.Capabilities
+		success             bool
+	}{
+		{name: "read file", input: fmt.Sprintf(`read_file("%v")`, filepath.Join(allowed, "foo.txt")), output: "foo", capabilities: capabilities, success: true},
+		{name: "read file (denied)", input: fmt.Sprintf(`read_file("%v")`, filepath.Join(denied, "bar.txt")), output: "permission denied", capabilities: capabilities},
+		{name: "read file (escaping allowed path)", input: fmt.Sprintf(`read_file("%v/../%v/bar.txt")`, allowed, filepath.Base(denied)), output: "permission denied", capabilities: capabilities},
+		{name: "read file (symbolic link followed by ..)", input: fmt.Sprintf(`read_file("%v")`, filepath.Join(allowed, "link")+"/../bar.txt"), output: "permission denied", capabilities: capabilities},
+		{name: "list directory (symbolic link followed by ..)", input: fmt.Sprintf(`list_dir("%v")`, filepath.Join(allowed, "link")+"/.."), output: "permission denied", capabilities: capabilities},
+		{name: "write file (symbolic link followed by ..)", input: fmt.Sprintf(`write_file("%v", "baz")`, filepath.Join(allowed, "link")+"/../baz.txt"), output: "permission denied", capabilities: capabilities},
+		{name: "write file (dangling symbolic link)", input: fmt.Sprintf(`write_file("%v", "baz")`, filepath.Join(allowed, "dangling")), output: "permission denied", capabilities: capabilities},
+		{name: "write file (through dangling symbolic link)", input: fmt.Sprintf(`write_file("%v", "baz")`, filepath.Join(allowed, "dangling")+"/../created.txt"), output: "permission denied", capabilities: capabilities},
+		{name: "read file (dangling symbolic link)", input: fmt.Sprintf(`read_file("%v")`, filepath.Join(allowed, "dangling")), output: "permission denied", capabilities: capabilities},
+		{name: "write file", input: fmt.Sprintf(`var path = "%v"; write_file(path, "baz"); read_file(path)`, filepath.Join(allowed, "out", "baz.txt")), output: "baz", capabilities: capabilities, success: true},
+		{name: "write file (denied)", input: fmt.Sprintf(`write_file("%v", "baz")`, filepath.Join(denied, "baz.txt")), output: "permission denied", capabilities: capabilities},
+		{name: "list directory", input: fmt.Sprintf(`list_dir("%v")`, allowed), output: "[dangling, foo.txt, link, out]", capabilities: capabilities, success: true},
+		{name: "list directory (denied)", input: fmt.Sprintf(`list_dir("%v")`, denied), output: "permission denied", capabilities: capabilities},
+		{name: "environment variable", input: `env("MARBLE_TEST")`, output: "marble", capabilities: eval.Capabilities{Env: true}, success: true},
+		{name: "missing environment variable", input: `env("MARBLE_TEST_MISSING")`, output: "null", capabilities: eval.Capabilities{Env: true}, success: true},
+		{name: "environment variable (denied)", input: `env("MARBLE_TEST")`, output: "permission denied", capabilities: capabilities},
+		{name: "arguments", input: `args()`, output: "[one, two]", capabilities: capabilities, success: true},
+		{name: "exit (denied)", input: `exit(2)`, output: "permission denied", capabilities: capabilities},
+		{name: "execute command", input: `exec("echo", "marble")`, output: "marble\n", capabilities: eval.Capabilities{Exec: true}, success: true},
+		{name: "execute failing command", input: `exec("false")`, output: "command 'false' failed", capabilities: eval.Capabilities{Exec: true}},
+		{name: "execute command (denied)", input: `exec("echo", "marble")`, output: "permission denied", capabilities: capabilities},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := eval.NewLexer([]byte(test.input))
+			p := eval.NewParser(l)
+			program := p.ParseProgram()
+			actualErrors := p.Errors()
+			if len(actualErrors) != 0 {
+				t.Fatalf("unexpected errors: %v", actualErrors)
+			}
+			env := eval.NewEnvironment()
+			env.LoadOS(test.capabilities)
+			evaluated := eval.Eval(program, env)
+			var actuatlOutput string
+			if evaluated != nil {
+				actuatlOutput = evaluated.String()
+			}
+			if test.success && actuatlOutput != test.output {
+				t.Fatalf("unexpected output, got=%v want=%v", actuatlOutput, test.output)
+			}
+			if !test.success && !strings.Contains(actuatlOutput, test.output) {
+				t.Fatalf("unexpected output, got=%v want=%v", actuatlOutput, test.output)
+			}
+		})
+	}
+	if _, err := os.Lstat(filepath.Join(denied, "created.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("unexpected file outside the allowed paths: %v", err)
+	}
+}
@@ -3,18 +3,23 @@ package marble
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 const (
 	_ = iota
 	lowest
+	assign          // x = y, object.field = x
+	coalesce        // x ?? y
 	equals          // ==, !=
 	less_greater    // <, >, >=, <=
+	pipe            // x |> f
 	add_subtract    // +, -
 	multiply_divide // *, /
 	prefix          // -x, !x
 	call            // myFunction(x)
 	index           // array[index]
+	member          // object.field
 )
 
 type parser struct {
@@ -24,6 +29,9 @@ type parser struct {
 
 	next    Token
 	current Token
+
+	functions int  // depth of the function bodies being parsed
+	yielded   bool // the function body being parsed contains a yield expression
 }
 
 func NewParser(l *lexer) *parser {
@@ -48,6 +56,9 @@ func (p *parser) ParseProgram() *program {
 		}
 		p.nextToken()
 	}
+	if len(p.issues) == 0 {
+		resolve(program)
+	}
 
 	return program
 }
@@ -57,6 +68,12 @@ func (p *parser) nextToken() {
 	p.next = p.l.NextToken()
 }
 
+// peekSecond returns the token after the next token without consuming any input.
+func (p *parser) peekSecond() Token {
+	l := *p.l
+	return l.NextToken()
+}
+
 func (p *parser) expectToken(t TokenType) bool {
 	if p.next.Type == t {
 		p.nextToken()
@@ -68,13 +85,18 @@ func (p *parser) expectToken(t TokenType) bool {
 
 func (p *parser) parseStatement() statement {
 	switch p.current.Type {
-	case VARIABLE:
+	case VARIABLE, CONSTANT:
 		if stmt := p.parseVarStatement(); stmt != nil {
 			return stmt
 		}
 		return nil
 	case RETURN:
 		return p.parseReturnStatement()
+	case STRUCT:
+		if stmt := p.parseStructStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -82,15 +104,44 @@ func (p *parser) parseStatement() statement {
 
 func (p *parser) parseVarStatement() *varStatement {
 	stmt := &varStatement{token: p.current}
-	if !p.expectToken(IDENTIFIER) {
+	if stmt.target = p.parseTarget(); stmt.target == nil {
 		return nil
 	}
-	stmt.name = &identifier{token: p.current}
 	if !p.expectToken(ASSIGN) {
 		return nil
 	}
 	p.nextToken()
 	stmt.value = p.parseExpression(lowest)
+	binding, ok := stmt.target.(*bindingPattern)
+	if function, isFunction := stmt.value.(*functionExpression); ok && isFunction && function != nil && function.name == nil {
+		function.name = binding.name
+	}
+	if p.next.Type == SEMICOLON {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *parser) parseStructStatement() *structStatement {
+	stmt := &structStatement{token: p.current}
+	if !p.expectToken(IDENTIFIER) {
+		return nil
+	}
+	stmt.name = &identifier{token: p.current}
+	if !p.expectToken(LBRACE) {
+		return nil
+	}
+	stmt.fields = make([]*identifier, 0)
+	for p.next.Type != RBRACE {
+		if !p.expectToken(IDENTIFIER) {
+			return nil
+		}
+		stmt.fields = append(stmt.fields, &identifier{token: p.current})
+		if p.next.Type != RBRACE && !p.expectToken(COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
 	if p.next.Type == SEMICOLON {
 		p.nextToken()
 	}
@@ -118,18 +169,26 @@ func (p *parser) parseExpressionStatement() *expressionStatement {
 
 func tokenPrecedence(t TokenType) int {
 	switch t {
+	case ASSIGN:
+		return assign
+	case COALESCE:
+		return coalesce
 	case EQ, NOTEQ:
 		return equals
 	case LT, GT, LTE, GTE:
 		return less_greater
+	case PIPE:
+		return pipe
 	case ADD, SUBTRACT:
 		return add_subtract
 	case MULTIPLY, DIVIDE:
 		return multiply_divide
 	case LPAREN:
 		return call
-	case LBRACKET:
+	case LBRACKET, OPTIONAL_LBRACKET:
 		return index
+	case DOT, OPTIONAL_DOT:
+		return member
 	default:
 		return lowest
 	}
@@ -146,18 +205,34 @@ func (p *parser) parseExpression(precedence int) expression {
 		left = p.parseFloatLiteral()
 	case TRUE, FALSE:
 		left = &booleanLiteral{token: p.current, value: p.current.Type == TRUE}
+	case NULL:
+		left = &nullLiteral{token: p.current}
 	case STRING:
-		left = &stringLiteral{token: p.current}
+		left = newStringLiteral(p.current)
+	case REGEX:
+		left = p.parseRegexLiteral()
 	case LBRACKET:
 		left = p.parseArrayLiteral()
+	case LBRACE:
+		left = p.parseMapLiteral()
 	case SUBTRACT, NEGATE:
 		left = p.parsePrefixExpression()
 	case LPAREN:
-		left = p.parseGroupedExpression()
+		if p.isArrowFunction() {
+			left = p.parseArrowFunction(lowest)
+		} else {
+			left = p.parseGroupedExpression()
+		}
 	case IF:
 		left = p.parseIfExpression()
 	case FUNCTION:
 		left = p.parseFunctionExpression()
+	case MATCH:
+		left = p.parseMatchExpression()
+	case YIELD:
+		left = p.parseYieldExpression()
+	case SPAWN:
+		left = p.parseSpawnExpression()
 	default:
 		p.issues = append(p.issues, fmt.Sprintf("missing prefix parse function for %v", p.current.Type))
 		return nil
@@ -165,15 +240,33 @@ func (p *parser) parseExpression(precedence int) expression {
 
 	for p.next.Type != SEMICOLON && precedence < tokenPrecedence(p.next.Type) {
 		switch p.next.Type {
-		case ADD, SUBTRACT, MULTIPLY, DIVIDE, EQ, NOTEQ, LT, LTE, GT, GTE:
+		case ADD, SUBTRACT, MULTIPLY, DIVIDE, EQ, NOTEQ, LT, LTE, GT, GTE, COALESCE:
 			p.nextToken()
 			left = p.parseInfixExpression(left)
+		case PIPE:
+			p.nextToken()
+			left = p.parsePipeExpression(left)
 		case LPAREN:
 			p.nextToken()
 			left = p.parseCallExpression(left)
-		case LBRACKET:
+		case LBRACKET, OPTIONAL_LBRACKET:
 			p.nextToken()
 			left = p.parseIndexExpression(left)
+		case DOT, OPTIONAL_DOT:
+			p.nextToken()
+			left = p.parseMemberExpression(left)
+		case ASSIGN:
+			if optional, ok := optionalAccess(left); ok {
+				p.issues = append(p.issues, fmt.Sprintf("line %v column %v: cannot assign to optional access %v", optional.LineNumber, optional.ColNumber, left.String()))
+				return nil
+			}
+			switch left.(type) {
+			case *identifier, *memberExpression:
+			default:
+				return left
+			}
+			p.nextToken()
+			left = p.parseAssignExpression(left)
 		default:
 			return left
 		}
@@ -201,22 +294,48 @@ func (p *parser) parseFloatLiteral() *floatLiteral {
 
 func (p *parser) parseArrayLiteral() *arrayLiteral {
 	e := &arrayLiteral{token: p.current}
-	e.elements = p.parseExpressionList(RBRACKET)
+	e.elements = p.parseExpressionList(RBRACKET, false)
+	return e
+}
+
+func (p *parser) parseMapLiteral() *mapLiteral {
+	e := &mapLiteral{token: p.current}
+	for p.next.Type != RBRACE {
+		p.nextToken()
+		switch p.current.Type {
+		case STRING:
+			e.keys = append(e.keys, newStringLiteral(p.current))
+		case IDENTIFIER:
+			e.keys = append(e.keys, newStringLiteral(Token{Type: STRING, Literal: p.current.Literal, LineNumber: p.current.LineNumber, ColNumber: p.current.ColNumber}))
+		default:
+			p.issues = append(p.issues, fmt.Sprintf("line %v column %v: expected map key to be %v or %v, got %v instead", p.current.LineNumber, p.current.ColNumber, STRING, IDENTIFIER, p.current.Type))
+			return nil
+		}
+		if !p.expectToken(COLON) {
+			return nil
+		}
+		p.nextToken()
+		e.values = append(e.values, p.parseExpression(lowest))
+		if p.next.Type != RBRACE && !p.expectToken(COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
 	return e
 }
 
-func (p *parser) parseExpressionList(end TokenType) []expression {
+func (p *parser) parseExpressionList(end TokenType, named bool) []expression {
 	expressions := make([]expression, 0)
 	if p.next.Type == end {
 		p.nextToken()
 		return expressions
 	}
 	p.nextToken()
-	expressions = append(expressions, p.parseExpression(lowest))
+	expressions = append(expressions, p.parseListElement(named))
 	for p.next.Type == COMMA {
 		p.nextToken()
 		p.nextToken()
-		expressions = append(expressions, p.parseExpression(lowest))
+		expressions = append(expressions, p.parseListElement(named))
 	}
 	if !p.expectToken(end) {
 		return nil
@@ -224,6 +343,23 @@ func (p *parser) parseExpressionList(end TokenType) []expression {
 	return expressions
 }
 
+func (p *parser) parseListElement(named bool) expression {
+	switch {
+	case p.current.Type == ELLIPSIS:
+		e := &spreadExpression{token: p.current}
+		p.nextToken()
+		e.value = p.parseExpression(lowest)
+		return e
+	case named && p.current.Type == IDENTIFIER && p.next.Type == COLON:
+		e := &namedArgument{name: &identifier{token: p.current}}
+		p.nextToken()
+		p.nextToken()
+		e.value = p.parseExpression(lowest)
+		return e
+	}
+	return p.parseExpression(lowest)
+}
+
 func (p *parser) parsePrefixExpression() *prefixExpression {
 	e := &prefixExpression{operator: p.current}
 	p.nextToken()
@@ -290,36 +426,136 @@ func (p *parser) parseFunctionExpression() *functionExpression {
 	if !p.expectToken(LPAREN) {
 		return nil
 	}
+	if p.next.Type == IDENTIFIER && p.peekSecond().Type == IDENTIFIER {
+		p.nextToken()
+		e.receiver = &identifier{token: p.current}
+		p.nextToken()
+		e.receiverType = &identifier{token: p.current}
+		if !p.expectToken(RPAREN) || !p.expectToken(IDENTIFIER) {
+			return nil
+		}
+		e.name = &identifier{token: p.current}
+		if !p.expectToken(LPAREN) {
+			return nil
+		}
+	}
 	e.parameters = p.parseFunctionParameters()
 	if !p.expectToken(LBRACE) {
 		return nil
 	}
-	e.body = p.parseBlockStatement()
+	p.parseFunctionBody(e, p.parseBlockStatement)
 	return e
 }
 
-func (p *parser) parseFunctionParameters() []*identifier {
-	identifiers := make([]*identifier, 0)
-	if p.next.Type == RPAREN {
-		p.nextToken()
-		return identifiers
+// parseFunctionBody parses the body of the function, marking it as a generator when the body yields.
+func (p *parser) parseFunctionBody(e *functionExpression, parse func() *blockStatement) {
+	yielded := p.yielded
+	p.yielded = false
+	p.functions++
+	e.body = parse()
+	p.functions--
+	e.generator, p.yielded = p.yielded, yielded
+}
+
+// isArrowFunction reports whether the current LPAREN token starts the parameters of (x) => x.
+func (p *parser) isArrowFunction() bool {
+	l := *p.l
+	depth := 1
+	for t := p.next; t.Type != EOF; t = l.NextToken() {
+		switch t.Type {
+		case LPAREN:
+			depth++
+		case RPAREN:
+			depth--
+			if depth == 0 {
+				return l.NextToken().Type == ARROW
+			}
+		}
+	}
+	return false
+}
+
+// parseArrowFunction parses an arrow function, an expression body is parsed with the precedence.
+func (p *parser) parseArrowFunction(precedence int) *functionExpression {
+	e := &functionExpression{token: p.current, arrow: true}
+	e.parameters = p.parseFunctionParameters()
+	if e.parameters == nil || !p.expectToken(ARROW) {
+		return nil
 	}
 	p.nextToken()
-	identifiers = append(identifiers, &identifier{token: p.current})
-	for p.next.Type == COMMA {
+	p.parseFunctionBody(e, func() *blockStatement { return p.parseExpressionBody(precedence) })
+	return e
+}
+
+// parseExpressionBody parses either a block or a single expression with the precedence wrapped in a block.
+func (p *parser) parseExpressionBody(precedence int) *blockStatement {
+	if p.current.Type == LBRACE {
+		return p.parseBlockStatement()
+	}
+	body := &expressionStatement{token: p.current}
+	body.value = p.parseExpression(precedence)
+	return &blockStatement{token: body.token, statements: []statement{body}}
+}
+
+func (p *parser) parseFunctionParameters() []*parameter {
+	parameters := make([]*parameter, 0)
+	if p.next.Type == RPAREN {
 		p.nextToken()
+		return parameters
+	}
+	for {
+		param := p.parseParameter()
+		if param == nil {
+			return nil
+		}
+		parameters = append(parameters, param)
+		if param.variadic || p.next.Type != COMMA {
+			break
+		}
 		p.nextToken()
-		identifiers = append(identifiers, &identifier{token: p.current})
 	}
 	if !p.expectToken(RPAREN) {
 		return nil
 	}
-	return identifiers
+	return parameters
+}
+
+func (p *parser) parseParameter() *parameter {
+	param := &parameter{}
+	if p.next.Type == ELLIPSIS {
+		p.nextToken()
+		param.variadic = true
+	}
+	if param.variadic {
+		if !p.expectToken(IDENTIFIER) {
+			return nil
+		}
+		param.target = &bindingPattern{name: &identifier{token: p.current}}
+		return param
+	}
+	if param.target = p.parseTarget(); param.target == nil {
+		return nil
+	}
+	if p.next.Type == ASSIGN {
+		p.nextToken()
+		p.nextToken()
+		param.defaultValue = p.parseExpression(lowest)
+	}
+	return param
 }
 
 func (p *parser) parseCallExpression(function expression) *callExpression {
 	e := &callExpression{token: p.current, function: function}
-	e.arguments = p.parseExpressionList(RPAREN)
+	e.arguments = p.parseExpressionList(RPAREN, true)
+	var named bool
+	for i := range e.arguments {
+		if _, ok := e.arguments[i].(*namedArgument); ok {
+			named = true
+		} else if named {
+			p.issues = append(p.issues, fmt.Sprintf("line %v column %v: positional argument after named argument", e.token.LineNumber, e.token.ColNumber))
+			return nil
+		}
+	}
 	return e
 }
 
@@ -332,3 +568,241 @@ func (p *parser) parseIndexExpression(left expression) *indexExpression {
 	}
 	return e
 }
+
+func (p *parser) parseMemberExpression(left expression) *memberExpression {
+	e := &memberExpression{token: p.current, left: left}
+	// a keyword following the dot is a member name, e.g. re.match(s) or m.if
+	if isKeyword(p.next) {
+		p.next.Type = IDENTIFIER
+	}
+	if !p.expectToken(IDENTIFIER) {
+		return nil
+	}
+	e.member = &identifier{token: p.current}
+	return e
+}
+
+// optionalAccess returns the token of the first optional member or index access the value of the expression depends on,
+// e.g. ?. in a?.b.c, as assigning through it would be skipped whenever the access short-circuits.
+func optionalAccess(e expression) (Token, bool) {
+	for {
+		switch current := e.(type) {
+		case *memberExpression:
+			if current.token.Type == OPTIONAL_DOT {
+				return current.token, true
+			}
+			e = current.left
+		case *indexExpression:
+			if current.token.Type == OPTIONAL_LBRACKET {
+				return current.token, true
+			}
+			e = current.left
+		case *callExpression:
+			e = current.function
+		default:
+			return Token{}, false
+		}
+	}
+}
+
+func (p *parser) parseAssignExpression(target expression) *assignExpression {
+	e := &assignExpression{token: p.current, target: target}
+	p.nextToken()
+	e.value = p.parseExpression(lowest)
+	return e
+}
+
+// parsePipeExpression desugars x |> f into f(x) and x |> f(y) into f(x, y). The value is passed to the call of the
+// callee, so x |> f(y)(z) is f(x, y)(z), and the body of an arrow function ends at the next pipe.
+func (p *parser) parsePipeExpression(left expression) *callExpression {
+	token := p.current
+	p.nextToken()
+	var right expression
+	if p.current.Type == LPAREN && p.isArrowFunction() {
+		right = p.parseArrowFunction(pipe)
+	} else {
+		right = p.parseExpression(pipe)
+	}
+	call, ok := right.(*callExpression)
+	if !ok || call == nil {
+		return &callExpression{token: token, function: right, arguments: []expression{left}}
+	}
+	callee := call
+	for inner, ok := callee.function.(*callExpression); ok && inner != nil; inner, ok = callee.function.(*callExpression) {
+		callee = inner
+	}
+	callee.arguments = append([]expression{left}, callee.arguments...)
+	return call
+}
+
+func (p *parser) parseMatchExpression() *matchExpression {
+	e := &matchExpression{token: p.current}
+	if !p.expectToken(LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	e.value = p.parseExpression(lowest)
+	if !p.expectToken(RPAREN) || !p.expectToken(LBRACE) {
+		return nil
+	}
+	for p.next.Type != RBRACE {
+		p.nextToken()
+		arm := &matchArm{pattern: p.parsePattern()}
+		if arm.pattern == nil || !p.expectToken(ARROW) {
+			return nil
+		}
+		p.nextToken()
+		block := p.current.Type == LBRACE
+		arm.body = p.parseExpressionBody(lowest)
+		e.arms = append(e.arms, arm)
+		if p.next.Type == COMMA {
+			p.nextToken()
+		} else if !block && p.next.Type != RBRACE {
+			p.issues = append(p.issues, fmt.Sprintf("line %v column %v: expected next token to be %v or %v, got %v instead", p.next.LineNumber, p.next.ColNumber, COMMA, RBRACE, p.next.Type))
+			return nil
+		}
+	}
+	p.nextToken()
+	return e
+}
+
+// parseTarget parses the identifier or destructuring pattern following the current token.
+func (p *parser) parseTarget() pattern {
+	if p.next.Type != LBRACKET && p.next.Type != LBRACE {
+		if !p.expectToken(IDENTIFIER) {
+			return nil
+		}
+	} else {
+		p.nextToken()
+	}
+	return p.parseSinglePattern()
+}
+
+func (p *parser) parsePattern() pattern {
+	first := p.parseSinglePattern()
+	if first == nil || p.next.Type != BAR {
+		return first
+	}
+	alternatives := &alternativePattern{alternatives: []pattern{first}}
+	for p.next.Type == BAR {
+		p.nextToken()
+		p.nextToken()
+		alternative := p.parseSinglePattern()
+		if alternative == nil {
+			return nil
+		}
+		alternatives.alternatives = append(alternatives.alternatives, alternative)
+	}
+	return alternatives
+}
+
+func (p *parser) parseSinglePattern() pattern {
+	switch p.current.Type {
+	case INTEGER, FLOAT, STRING, TRUE, FALSE, NULL:
+		return &literalPattern{value: p.parseExpression(prefix)}
+	case SUBTRACT:
+		if p.next.Type == INTEGER || p.next.Type == FLOAT {
+			return &literalPattern{value: p.parsePrefixExpression()}
+		}
+	case IDENTIFIER:
+		if p.current.Literal == "_" {
+			return &wildcardPattern{token: p.current}
+		}
+		return &bindingPattern{name: &identifier{token: p.current}}
+	case LBRACKET:
+		pat := &arrayPattern{token: p.current, elements: make([]pattern, 0)}
+		for p.next.Type != RBRACKET {
+			p.nextToken()
+			if p.current.Type == ELLIPSIS {
+				if pat.rest = p.parseTarget(); pat.rest == nil || !p.expectToken(RBRACKET) {
+					return nil
+				}
+				return pat
+			}
+			element := p.parsePattern()
+			if element == nil {
+				return nil
+			}
+			pat.elements = append(pat.elements, element)
+			if p.next.Type != RBRACKET && !p.expectToken(COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+		return pat
+	case LBRACE:
+		pat := &mapPattern{token: p.current}
+		for p.next.Type != RBRACE {
+			p.nextToken()
+			if p.current.Type != IDENTIFIER && p.current.Type != STRING {
+				p.issues = append(p.issues, fmt.Sprintf("line %v column %v: expected map key to be %v or %v, got %v instead", p.current.LineNumber, p.current.ColNumber, STRING, IDENTIFIER, p.current.Type))
+				return nil
+			}
+			key := p.current
+			var value pattern
+			if p.next.Type == COLON {
+				p.nextToken()
+				p.nextToken()
+				if value = p.parsePattern(); value == nil {
+					return nil
+				}
+			} else if key.Type == IDENTIFIER {
+				value = &bindingPattern{name: &identifier{token: key}}
+			} else if !p.expectToken(COLON) {
+				return nil
+			}
+			pat.keys = append(pat.keys, key)
+			pat.values = append(pat.values, value)
+			if p.next.Type != RBRACE && !p.expectToken(COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+		return pat
+	}
+	p.issues = append(p.issues, fmt.Sprintf("line %v column %v: invalid pattern %v", p.current.LineNumber, p.current.ColNumber, p.current.Type))
+	return nil
+}
+
+func (p *parser) parseYieldExpression() *yieldExpression {
+	e := &yieldExpression{token: p.current}
+	if p.functions == 0 {
+		p.issues = append(p.issues, fmt.Sprintf("line %v column %v: yield outside of a function", p.current.LineNumber, p.current.ColNumber))
+		return nil
+	}
+	p.yielded = true
+	if p.next.Type == ELLIPSIS {
+		p.nextToken()
+		e.delegate = true
+	}
+	p.nextToken()
+	e.value = p.parseExpression(lowest)
+	return e
+}
+
+func (p *parser) parseRegexLiteral() *regexLiteral {
+	literal := p.current.Literal
+	end := strings.LastIndexByte(literal, '/')
+	if end == 0 {
+		p.issues = append(p.issues, fmt.Sprintf("line %v column %v: unterminated regular expression %v", p.current.LineNumber, p.current.ColNumber, literal))
+		return nil
+	}
+	value, err := compileRegex(literal[1:end], literal[end+1:])
+	if err != nil {
+		p.issues = append(p.issues, fmt.Sprintf("line %v column %v: invalid regular expression %v: %v", p.current.LineNumber, p.current.ColNumber, literal, err))
+		return nil
+	}
+	return &regexLiteral{token: p.current, value: value}
+}
+
+func (p *parser) parseSpawnExpression() *spawnExpression {
+	e := &spawnExpression{token: p.current}
+	p.nextToken()
+	call, ok := p.parseExpression(prefix).(*callExpression)
+	if !ok || call == nil {
+		p.issues = append(p.issues, fmt.Sprintf("line %v column %v: spawn expects a function call", e.token.LineNumber, e.token.ColNumber))
+		return nil
+	}
+	e.call = call
+	return e
+}
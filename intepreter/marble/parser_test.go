@@ -16,9 +16,32 @@ func TestParseValidProgram(t *testing.T) {
 		{name: "boolean comparison expression", input: "(true == false) != !false;", output: "((true == false) != (!false));"},
 		{name: "arithmetic comparison expression", input: "(1 - 5) < 6 == 7 > 10 <= (45 >= 22)", output: "(((1 - 5) < 6) == ((7 > 10) <= (45 >= 22)));"},
 		{name: "array expression", input: `[2, 5.6, "string", [true, false], func(){x + y}, []];`, output: `[2, 5.6, "string", [true, false], func(){(x + y);}, []];`},
+		{name: "map expression", input: `{"foo": 1 + 2, bar: [true], baz: {}}`, output: `{"foo": (1 + 2), "bar": [true], "baz": {}};`},
 		{name: "if expression", input: "if (true) { 8 + 9 * 10; } else { false; }", output: "if (true) {(8 + (9 * 10));} else {false;};"},
 		{name: "function call expression", input: "func (x) { } (a+b)", output: "func(x){}((a + b));"},
+		{name: "function parameters", input: "func(x, y = 10 * 2, ...rest) { x }", output: "func(x, y = (10 * 2), ...rest){x;};"},
+		{name: "spread and named arguments", input: "f(1, ...[2, 3], x: 4, y: a + b)", output: "f(1, ...[2, 3], x: 4, y: (a + b));"},
+		{name: "spread array elements", input: "[1, ...foo]", output: "[1, ...foo];"},
+		{name: "arrow function", input: "var f = (x, y = 2) => x * y; () => { 1 }; ((x)) + 1", output: "var f = (x, y = 2) => {(x * y);};() => {1;};(x + 1);"},
+		{name: "pipeline expression", input: "x + 1 |> f |> g(1) == 3", output: "(g(f((x + 1)), 1) == 3);"},
+		{name: "pipeline into arrow function", input: "x |> (y) => y * 2", output: "(y) => {(y * 2);}(x);"},
+		{name: "pipeline into curried call", input: "x |> f(1)(2)", output: "f(x, 1)(2);"},
+		{name: "pipeline chained after arrow function", input: "x |> (y) => y * 2 |> g(1) |> h", output: "h(g((y) => {(y * 2);}(x), 1));"},
+		{name: "pipeline inside arrow function", input: "f((y) => y |> g)", output: "f((y) => {g(y);});"},
+		{name: "match expression", input: `match (x + 1) { 1 => "one", -2.5 | "a" | true => { x }, [a, [_, b]] => a + b, _ => null }`, output: `match ((x + 1)) {1 => {"one";}, (-2.5) | "a" | true => {x;}, [a, [_, b]] => {(a + b);}, _ => {null;}};`},
+		{name: "yield expression", input: "func() { yield 1 + 2; (x) => yield ...x; }", output: "func(){(yield (1 + 2));(x) => {(yield ...x);};};"},
+		{name: "spawn expression", input: "spawn worker(1, 2); spawn list[0](1) |> wait;", output: "(spawn worker(1, 2));wait((spawn (list[0])(1)));"},
+		{name: "regex literal", input: `var re = /(\w+)@(\w+)/i; re.match(x)[1] / 2`, output: `var re = /(\w+)@(\w+)/i;(((re.match)(x)[1]) / 2);`},
+		{name: "null coalescing and optional access", input: "a ?? b?.c?[0] == null ?? 1", output: "((a ?? (((b?.c)?[0]) == null)) ?? 1);"},
 		{name: "array index expression", input: "array[6-7]*67", output: "((array[(6 - 7)]) * 67);"},
+		{name: "struct statement", input: "struct Point { x, y, }; struct Empty {}", output: "struct Point {x, y}struct Empty {}"},
+		{name: "method expression", input: "func (p Point) norm(scale) { p.x * scale }", output: "func (p Point) norm(scale){((p.x) * scale);};"},
+		{name: "member expression", input: "foo.bar[0].baz(1) + -a.b", output: "((((foo.bar)[0]).baz)(1) + (-(a.b)));"},
+		{name: "assignment", input: "foo = bar = 1", output: "(foo = (bar = 1));"},
+		{name: "const statement", input: "const foo = 1;", output: "const foo = 1;"},
+		{name: "member assignment", input: "foo.bar = foo.baz = 1 + 2", output: "((foo.bar) = ((foo.baz) = (1 + 2)));"},
+		{name: "destructuring var statement", input: `const [a, [_, b], ...rest] = foo; var {name, "full name": full, address: {city}} = bar;`, output: `const [a, [_, b], ...rest] = foo;var {name, "full name": full, address: {city}} = bar;`},
+		{name: "destructuring parameters", input: "func([a, b], {c} = {c: 1}, ...rest) {}", output: `func([a, b], {c} = {"c": 1}, ...rest){};`},
 		{name: "var statement", input: `var foo = [9, 9.9, "bar", [true, false], 9 + 9.9];`, output: `var foo = [9, 9.9, "bar", [true, false], (9 + 9.9)];`},
 		{name: "return statement", input: "var foo = 2.3; foo; 1; var y = if (true) {true}; var bar = 6.9; return foo;", output: "var foo = 2.3;foo;1;var y = if (true) {true;};var bar = 6.9;return foo;"},
 	}
@@ -48,6 +71,8 @@ func TestParseInvalidProgram(t *testing.T) {
 		{name: "invalid prefix (expression statement)", input: "x true = 6;", issue: "missing prefix parse function for "},
 		{name: "invalid integer (integer)", input: "92233720368547758079223372036854775807;", issue: "could not parse "},
 		{name: "missing right bracket (array)", input: "[1, 2, 3, 4;", issue: "expected next token to be "},
+		{name: "invalid key (map)", input: "{1: 2}", issue: "expected map key to be "},
+		{name: "missing colon (map)", input: `{"foo" 2}`, issue: "expected next token to be "},
 		{name: "missing right parenthesis (grouped expression)", input: "(1 + 2 * 3 / 4", issue: "expected next token to be "},
 		{name: "missing left parenthesis (if expression)", input: "if", issue: "expected next token to be "},
 		{name: "missing right parenthesis (if expression)", input: "if (true", issue: "expected next token to be "},
@@ -56,7 +81,32 @@ func TestParseInvalidProgram(t *testing.T) {
 		{name: "missing left parenthesis (function expression)", input: "func", issue: "expected next token to be "},
 		{name: "missing right parenthesis (function expression)", input: "func(", issue: "expected next token to be "},
 		{name: "missing left curly brace (function expression)", input: "func()", issue: "expected next token to be "},
+		{name: "parameter after variadic parameter (function expression)", input: "func(...rest, x) {}", issue: "expected next token to be )"},
+		{name: "invalid parameter (function expression)", input: "func(1) {}", issue: "expected next token to be IDENTIFIER"},
+		{name: "positional after named argument (call expression)", input: "f(x: 1, 2)", issue: "positional argument after named argument"},
+		{name: "missing body (arrow function)", input: "(x) =>", issue: "missing prefix parse function for EOF"},
+		{name: "invalid parameter (arrow function)", input: "(x + 1) => x", issue: "expected next token to be )"},
+		{name: "invalid pattern (match expression)", input: "match (x) { x + 1 => 1 }", issue: "expected next token to be =>"},
+		{name: "invalid literal pattern (match expression)", input: "match (x) { (1) => 1 }", issue: "invalid pattern ("},
+		{name: "missing comma (match expression)", input: "match (x) { 1 => 1 2 => 2 }", issue: "expected next token to be , or }"},
+		{name: "element after rest (destructuring var statement)", input: "var [...rest, a] = foo;", issue: "expected next token to be ]"},
+		{name: "invalid key (destructuring var statement)", input: "var {1} = foo;", issue: "expected map key to be "},
+		{name: "spawn without a call", input: "spawn worker;", issue: "spawn expects a function call"},
+		{name: "yield outside of a function", input: "yield 1;", issue: "yield outside of a function"},
 		{name: "missing right bracket (array index expression)", input: "array[0", issue: "expected next token to be "},
+		{name: "missing name (struct statement)", input: "struct {}", issue: "expected next token to be "},
+		{name: "invalid field (struct statement)", input: "struct Point { x y }", issue: "expected next token to be "},
+		{name: "missing name (method expression)", input: "func (p Point) () {}", issue: "expected next token to be "},
+		{name: "missing member (member expression)", input: "foo.1", issue: "expected next token to be "},
+		{name: "invalid regex literal", input: "var x = 1;\nvar re = /a(b/;", issue: "line 2 column 10: invalid regular expression /a(b/: error parsing regexp: missing closing )"},
+		{name: "unknown regex flag", input: "/a/g", issue: "unknown regular expression flag 'g'"},
+		{name: "unterminated regex literal", input: "[/a]", issue: "line 1 column 2: unterminated regular expression /a]"},
+		{name: "missing member (optional member expression)", input: "foo?.1", issue: "expected next token to be IDENTIFIER"},
+		{name: "invalid question mark", input: "a ? b", issue: "missing prefix parse function for ILLEGAL"},
+		{name: "invalid assignment target", input: "foo + 1 = 1", issue: "missing prefix parse function for ="},
+		{name: "optional member assignment", input: "a?.b = 1", issue: "line 1 column 2: cannot assign to optional access (a?.b)"},
+		{name: "optional index assignment", input: "a?[0] = 1", issue: "line 1 column 2: cannot assign to optional access (a?[0])"},
+		{name: "assignment through optional access", input: "a?.b.c = 1", issue: "line 1 column 2: cannot assign to optional access ((a?.b).c)"},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -0,0 +1,133 @@
+package marble
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type functionProfile struct {
+	name    string
+	line    int
+	calls   int
+	active  int // calls that have not returned, recursive calls are timed once
+	started time.Time
+	elapsed time.Duration
+}
+
+type position struct {
+	line, column int
+}
+
+// Profiler records function calls with their cumulative time and how many times the statements on each line ran.
+type Profiler struct {
+	mu        sync.Mutex
+	functions map[position]*functionProfile // keyed by the position of the function body
+	lines     map[int]int
+}
+
+// NewProfiler creates a profiler for the program, every line with a statement is part of the coverage profile.
+func NewProfiler(p *program) *Profiler {
+	profiler := &Profiler{functions: make(map[position]*functionProfile), lines: make(map[int]int)}
+	var walk func(n *ASTNode)
+	walk = func(n *ASTNode) {
+		switch n.Type {
+		case "VarStatement", "ReturnStatement", "ExpressionStatement", "StructStatement":
+			profiler.lines[n.Line] += 0
+		case "FunctionExpression":
+			body := n.Children[len(n.Children)-1]
+			profiler.function(n.Value, position{line: body.Line, column: body.Column})
+		}
+		for i := range n.Children {
+			walk(n.Children[i])
+		}
+	}
+	walk(p.Tree())
+	return profiler
+}
+
+func (p *Profiler) beforeStatement(s statement, _ *environment) *objError {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lines[statementToken(s).LineNumber]++
+	return nil
+}
+
+func (p *Profiler) call(f *frame) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	profile := p.function(f.function, position{line: f.definition.LineNumber, column: f.definition.ColNumber})
+	profile.calls++
+	profile.active++
+	if profile.active == 1 {
+		profile.started = time.Now()
+	}
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		profile.active--
+		if profile.active == 0 {
+			profile.elapsed += time.Since(profile.started)
+		}
+	}
+}
+
+func (p *Profiler) function(name string, body position) *functionProfile {
+	profile, ok := p.functions[body]
+	if !ok {
+		if name == "" {
+			name = "anonymous function"
+		}
+		profile = &functionProfile{name: name, line: body.line}
+		p.functions[body] = profile
+	}
+	return profile
+}
+
+// WriteReport writes the functions ordered by cumulative time followed by the statement count of each line.
+func (p *Profiler) WriteReport(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	functions := slices.SortedFunc(maps.Values(p.functions), func(a, b *functionProfile) int {
+		return cmp.Or(cmp.Compare(b.elapsed, a.elapsed), cmp.Compare(a.line, b.line), strings.Compare(a.name, b.name))
+	})
+	output := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(output, "FUNCTION\tLINE\tCALLS\tCUMULATIVE TIME")
+	for _, f := range functions {
+		_, _ = fmt.Fprintf(output, "%v\t%v\t%v\t%v\n", f.name, f.line, f.calls, f.elapsed)
+	}
+	_, _ = fmt.Fprintln(output, "\nLINE\tCOUNT")
+	for _, line := range slices.Sorted(maps.Keys(p.lines)) {
+		_, _ = fmt.Fprintf(output, "%v\t%v\n", line, p.lines[line])
+	}
+	return output.Flush()
+}
+
+// WriteCoverage writes the statement count of each line in the LCOV format, filename is the path of the script.
+func (p *Profiler) WriteCoverage(w io.Writer, filename string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var output strings.Builder
+	_, _ = fmt.Fprintf(&output, "SF:%v\n", filename)
+	for _, f := range slices.SortedFunc(maps.Values(p.functions), func(a, b *functionProfile) int { return cmp.Compare(a.line, b.line) }) {
+		_, _ = fmt.Fprintf(&output, "FN:%v,%v\nFNDA:%v,%v\n", f.line, f.name, f.calls, f.name)
+	}
+	var hit int
+	for _, line := range slices.Sorted(maps.Keys(p.lines)) {
+		if p.lines[line] > 0 {
+			hit++
+		}
+		_, _ = fmt.Fprintf(&output, "DA:%v,%v\n", line, p.lines[line])
+	}
+	_, _ = fmt.Fprintf(&output, "LF:%v\nLH:%v\nend_of_record\n", len(p.lines), hit)
+	_, err := io.WriteString(w, output.String())
+	return err
+}
@@ -0,0 +1,52 @@
+package marble_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	eval "github.com/o-richard/intepreter/marble"
+)
+
+func TestProfiler(t *testing.T) {
+	input := `var fib = func(n) {
+    if (n <= 1) {
+        return n;
+    }
+    return fib(n - 1) + fib(n - 2);
+};
+var unused = func() {
+    1;
+};
+fib(3);`
+	p := eval.NewParser(eval.NewLexer([]byte(input)))
+	program := p.ParseProgram()
+	if issues := p.Errors(); len(issues) != 0 {
+		t.Fatalf("unexpected errors: %v", issues)
+	}
+	profiler := eval.NewProfiler(program)
+	env := eval.NewEnvironment()
+	env.Trace(profiler)
+	if evaluated := eval.Eval(program, env); evaluated.String() != "2" {
+		t.Fatalf("unexpected result, got=%v want=2", evaluated.String())
+	}
+
+	var report bytes.Buffer
+	if err := profiler.WriteReport(&report); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"FUNCTION  LINE  CALLS  CUMULATIVE TIME\nfib       1     5", "unused    7     0      0s", "LINE  COUNT\n1     1\n2     5\n3     3\n5     2\n7     1\n8     0\n10    1\n"} {
+		if !strings.Contains(report.String(), expected) {
+			t.Fatalf("unexpected report, got=%q want=%q", report.String(), expected)
+		}
+	}
+
+	var coverage bytes.Buffer
+	if err := profiler.WriteCoverage(&coverage, "fib.marble"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "SF:fib.marble\nFN:1,fib\nFNDA:5,fib\nFN:7,unused\nFNDA:0,unused\nDA:1,1\nDA:2,5\nDA:3,3\nDA:5,2\nDA:7,1\nDA:8,0\nDA:10,1\nLF:7\nLH:6\nend_of_record\n"
+	if coverage.String() != expected {
+		t.Fatalf("unexpected coverage, got=%q want=%q", coverage.String(), expected)
+	}
+}
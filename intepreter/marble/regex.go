@@ -0,0 +1,171 @@
+package marble
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type objRegex struct {
+	regexp *regexp.Regexp
+	source string // the literal form, e.g. /a+b/i
+}
+
+func (o *objRegex) objectType() string { return REGEX_OBJ }
+func (o *objRegex) String() string     { return o.source }
+
+// compileRegex compiles the pattern in the RE2 syntax, the flags i, m and s enable case insensitive matching,
+// multi-line mode and . matching newlines.
+func compileRegex(pattern, flags string) (*objRegex, error) {
+	for _, flag := range flags {
+		if !strings.ContainsRune("ims", flag) {
+			return nil, fmt.Errorf("unknown regular expression flag '%c'", flag)
+		}
+	}
+	expression := pattern
+	if flags != "" {
+		expression = "(?" + flags + ")" + pattern
+	}
+	compiled, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return &objRegex{regexp: compiled, source: "/" + pattern + "/" + flags}, nil
+}
+
+// member returns the built-in functions of the regular expression bound to it, e.g. re.match(s) calls match(re, s).
+func (o *objRegex) member(token Token, name string) object {
+	var function func(token Token, args ...object) object
+	switch name {
+	case "match", "match_re":
+		function = builtinMatch
+	case "find_all":
+		function = builtinFindAll
+	case "replace_all":
+		function = builtinReplaceAll
+	case "split_re":
+		function = builtinSplitRegex
+	default:
+		return newError(token, "regular expression has no method '%v'", name)
+	}
+	return &objBuiltin{
+		function: func(token Token, args ...object) object {
+			return function(token, append([]object{o}, args...)...)
+		},
+	}
+}
+
+func builtinRegex(token Token, args ...object) object {
+	if maxArgs := 2; len(args) == 0 || len(args) > maxArgs {
+		return newError(token, "wrong number of arguments")
+	}
+	values := make([]string, len(args))
+	for i := range args {
+		arg, ok := args[i].(*objString)
+		if !ok {
+			return newError(token, "invalid argument type: %v", args[i].objectType())
+		}
+		values[i] = arg.value
+	}
+	flags := ""
+	if maxArgs := 2; len(values) == maxArgs {
+		flags = values[1]
+	}
+	compiled, err := compileRegex(values[0], flags)
+	if err != nil {
+		return newError(token, "invalid regular expression: %v", err)
+	}
+	return compiled
+}
+
+// regexArguments checks the arguments of a regular expression built-in function, a regular expression followed by
+// count strings. A string is accepted in place of the regular expression.
+func regexArguments(token Token, args []object, count int) (*regexp.Regexp, []string, *objError) {
+	if len(args) != count+1 {
+		return nil, nil, newError(token, "wrong number of arguments")
+	}
+	var re *objRegex
+	switch arg := args[0].(type) {
+	case *objRegex:
+		re = arg
+	case *objString:
+		compiled, err := compileRegex(arg.value, "")
+		if err != nil {
+			return nil, nil, newError(token, "invalid regular expression: %v", err)
+		}
+		re = compiled
+	default:
+		return nil, nil, newError(token, "invalid argument type: %v", args[0].objectType())
+	}
+	values := make([]string, count)
+	for i := range values {
+		arg, ok := args[i+1].(*objString)
+		if !ok {
+			return nil, nil, newError(token, "invalid argument type: %v", args[i+1].objectType())
+		}
+		values[i] = arg.value
+	}
+	return re.regexp, values, nil
+}
+
+// groups returns the match followed by its capture groups, groups that did not participate in the match are null.
+func groups(input string, indexes []int) *objArray {
+	elements := make([]object, len(indexes)/2)
+	for i := range elements {
+		if indexes[2*i] < 0 {
+			elements[i] = objectNull
+		} else {
+			elements[i] = &objString{value: input[indexes[2*i]:indexes[2*i+1]]}
+		}
+	}
+	return &objArray{elements: elements}
+}
+
+// builtinMatch returns the first match with its capture groups or null. match is a keyword, so the function is
+// named match_re and is also available as the method re.match(s).
+func builtinMatch(token Token, args ...object) object {
+	re, values, err := regexArguments(token, args, 1)
+	if err != nil {
+		return err
+	}
+	indexes := re.FindStringSubmatchIndex(values[0])
+	if indexes == nil {
+		return objectNull
+	}
+	return groups(values[0], indexes)
+}
+
+func builtinFindAll(token Token, args ...object) object {
+	re, values, err := regexArguments(token, args, 1)
+	if err != nil {
+		return err
+	}
+	matches := re.FindAllStringSubmatchIndex(values[0], -1)
+	elements := make([]object, len(matches))
+	for i := range matches {
+		elements[i] = groups(values[0], matches[i])
+	}
+	return &objArray{elements: elements}
+}
+
+// builtinReplaceAll replaces every match, $1 or ${name} in the replacement refer to capture groups.
+func builtinReplaceAll(token Token, args ...object) object {
+	re, values, err := regexArguments(token, args, 2)
+	if err != nil {
+		return err
+	}
+	return &objString{value: re.ReplaceAllString(values[0], values[1])}
+}
+
+func builtinSplitRegex(token Token, args ...object) object {
+	re, values, err := regexArguments(token, args, 1)
+	if err != nil {
+		return err
+	}
+	parts := re.Split(values[0], -1)
+	elements := make([]object, len(parts))
+	for i := range parts {
+		elements[i] = &objString{value: parts[i]}
+	}
+	return &objArray{elements: elements}
+}
@@ -0,0 +1,189 @@
+package marble
+
+// layout assigns a slot to every identifier declared in a scope, the environments created for the scope keep the
+// identifiers in a slice instead of a map.
+type layout struct {
+	names []string
+	index map[string]int
+}
+
+// scope mirrors an environment created during evaluation.
+type scope struct {
+	layout *layout
+	outer  *scope
+}
+
+type reference struct {
+	identifier *identifier
+	scope      *scope
+}
+
+// resolver pre-computes how many environments each identifier lookup skips and the slot it reads. A lookup resolves to the closest scope
+// that declares the identifier anywhere, the scopes in between never declare it so skipping them keeps the lookup
+// result unchanged. Identifiers that no scope declares, e.g. built-in functions, are looked up by name.
+type resolver struct {
+	scope      *scope
+	references []reference
+}
+
+func resolve(p *program) {
+	r := &resolver{}
+	r.enter()
+	for i := range p.statements {
+		r.statement(p.statements[i])
+	}
+	for _, ref := range r.references {
+		depth := 0
+		for s := ref.scope; s != nil; s, depth = s.outer, depth+1 {
+			if slot, ok := s.layout.index[ref.identifier.token.Literal]; ok {
+				ref.identifier.resolved, ref.identifier.depth, ref.identifier.slot = true, depth, slot
+				break
+			}
+		}
+	}
+}
+
+func (r *resolver) enter() *layout {
+	r.scope = &scope{layout: &layout{index: make(map[string]int)}, outer: r.scope}
+	return r.scope.layout
+}
+
+func (r *resolver) exit() {
+	r.scope = r.scope.outer
+}
+
+func (r *resolver) declare(name string) {
+	l := r.scope.layout
+	if _, ok := l.index[name]; !ok {
+		l.index[name] = len(l.names)
+		l.names = append(l.names, name)
+	}
+}
+
+func (r *resolver) statement(s statement) {
+	switch s := s.(type) {
+	case *varStatement:
+		r.expression(s.value)
+		r.pattern(s.target)
+	case *returnStatement:
+		r.expression(s.value)
+	case *expressionStatement:
+		r.expression(s.value)
+	case *structStatement:
+		r.declare(s.name.token.Literal)
+	case *blockStatement:
+		r.block(s)
+	}
+}
+
+// block resolves a block evaluated in its own environment.
+func (r *resolver) block(b *blockStatement) {
+	if b == nil {
+		return
+	}
+	b.layout = r.enter()
+	r.statements(b)
+	r.exit()
+}
+
+// statements resolves the statements of a block evaluated in the current environment, e.g. a function body.
+func (r *resolver) statements(b *blockStatement) {
+	for i := range b.statements {
+		r.statement(b.statements[i])
+	}
+}
+
+func (r *resolver) expressions(expressions []expression) {
+	for i := range expressions {
+		r.expression(expressions[i])
+	}
+}
+
+func (r *resolver) expression(e expression) {
+	switch e := e.(type) {
+	case *identifier:
+		r.references = append(r.references, reference{identifier: e, scope: r.scope})
+	case *arrayLiteral:
+		r.expressions(e.elements)
+	case *mapLiteral:
+		r.expressions(e.values)
+	case *prefixExpression:
+		r.expression(e.right)
+	case *infixExpression:
+		r.expression(e.left)
+		r.expression(e.right)
+	case *ifExpression:
+		r.expression(e.condition)
+		r.block(e.consequence)
+		r.block(e.alternative)
+	case *blockExpression:
+		r.block(e.block)
+	case *functionExpression:
+		// methods are called in an environment enclosing the one that binds the receiver
+		if e.receiver != nil {
+			e.receiverLayout = r.enter()
+			r.declare(e.receiver.token.Literal)
+		}
+		e.layout = r.enter()
+		for _, param := range e.parameters {
+			if param.defaultValue != nil {
+				r.expression(param.defaultValue)
+			}
+			r.pattern(param.target)
+		}
+		r.statements(e.body)
+		r.exit()
+		if e.receiver != nil {
+			r.exit()
+		}
+	case *spreadExpression:
+		r.expression(e.value)
+	case *namedArgument:
+		r.expression(e.value)
+	case *callExpression:
+		r.expression(e.function)
+		r.expressions(e.arguments)
+	case *indexExpression:
+		r.expression(e.left)
+		r.expression(e.index)
+	case *memberExpression:
+		r.expression(e.left)
+	case *assignExpression:
+		r.expression(e.target)
+		r.expression(e.value)
+	case *matchExpression:
+		r.expression(e.value)
+		for _, arm := range e.arms {
+			arm.layout = r.enter()
+			r.pattern(arm.pattern)
+			r.statements(arm.body)
+			r.exit()
+		}
+	case *yieldExpression:
+		r.expression(e.value)
+	case *spawnExpression:
+		r.expression(e.call)
+	}
+}
+
+func (r *resolver) pattern(pat pattern) {
+	switch pat := pat.(type) {
+	case *bindingPattern:
+		r.declare(pat.name.token.Literal)
+	case *arrayPattern:
+		for i := range pat.elements {
+			r.pattern(pat.elements[i])
+		}
+		if pat.rest != nil {
+			r.pattern(pat.rest)
+		}
+	case *mapPattern:
+		for i := range pat.values {
+			r.pattern(pat.values[i])
+		}
+	case *alternativePattern:
+		for i := range pat.alternatives {
+			r.pattern(pat.alternatives[i])
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package marble
+
+import (
+	"errors"
+	"strings"
+)
+
+func builtinAssert(token Token, args ...object) object {
+	if maxArgs := 2; len(args) == 0 || len(args) > maxArgs {
+		return newError(token, "wrong number of arguments")
+	}
+	if args[0] != objectNull && args[0] != objectFalse {
+		return objectNull
+	}
+	if len(args) == 2 {
+		return newError(token, "assertion failed: %v", args[1].String())
+	}
+	return newError(token, "assertion failed")
+}
+
+func builtinAssertEqual(token Token, args ...object) object {
+	if maxArgs := 2; len(args) != maxArgs {
+		return newError(token, "wrong number of arguments")
+	}
+	if !objectsEqual(args[0], args[1]) {
+		return newError(token, "assertion failed: %v != %v", args[0].String(), args[1].String())
+	}
+	return objectNull
+}
+
+// TestFunctions returns the names of the functions declared at the top level of the program whose names start with test_.
+func TestFunctions(p *program) []string {
+	names := make([]string, 0)
+	for i := range p.statements {
+		stmt, ok := p.statements[i].(*varStatement)
+		if !ok {
+			continue
+		}
+		binding, ok := stmt.target.(*bindingPattern)
+		if _, isFunction := stmt.value.(*functionExpression); ok && isFunction && strings.HasPrefix(binding.name.token.Literal, "test_") {
+			names = append(names, binding.name.token.Literal)
+		}
+	}
+	return names
+}
+
+// RunTest evaluates the program in a fresh environment and calls the test function without arguments.
+// The random number generator is seeded with 0 so every run returns the same values.
+func RunTest(p *program, name string) error {
+	env := NewEnvironment()
+	env.random.seed(0)
+	if evaluated := Eval(p, env); evaluated != nil {
+		if err, ok := evaluated.(*objError); ok {
+			return errors.New(err.message)
+		}
+	}
+	function, ok := env.get(name)
+	if !ok {
+		return errors.New("test function '" + name + "' not found")
+	}
+	if err, ok := applyFunction(Token{}, function, nil, nil, env).(*objError); ok {
+		return errors.New(err.message)
+	}
+	return nil
+}
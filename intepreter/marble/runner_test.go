@@ -0,0 +1,50 @@
+package marble_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	eval "github.com/o-richard/intepreter/marble"
+)
+
+func TestRunTest(t *testing.T) {
+	input := `var counter = 0;
+var add = (x, y) => x + y;
+var test_add = func() { assert_eq(add(1, 2), 3); };
+var test_counter = func() { counter = counter + 1; assert_eq(counter, 1); };
+var test_failure = func() {
+    assert(add(1, 1) == 3, "one plus one");
+};
+var test_random = func() { assert_eq(random(1000), 222); };
+var test_value = 1;
+var helper = func() {};`
+	p := eval.NewParser(eval.NewLexer([]byte(input)))
+	program := p.ParseProgram()
+	if issues := p.Errors(); len(issues) != 0 {
+		t.Fatalf("unexpected errors: %v", issues)
+	}
+	names := eval.TestFunctions(program)
+	if expected := []string{"test_add", "test_counter", "test_failure", "test_random"}; !slices.Equal(names, expected) {
+		t.Fatalf("unexpected test functions, got=%v want=%v", names, expected)
+	}
+
+	tests := []struct {
+		name, output string
+	}{
+		{name: "test_add", output: "<nil>"},
+		{name: "test_counter", output: "<nil>"},
+		{name: "test_counter", output: "<nil>"}, // each run starts from a fresh environment
+		{name: "test_failure", output: "line 6 col 11: assertion failed: one plus one"},
+		{name: "test_random", output: "<nil>"},
+		{name: "test_random", output: "<nil>"}, // each run seeds the random number generator
+		{name: "test_missing", output: "test function 'test_missing' not found"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actualOutput := fmt.Sprint(eval.RunTest(program, test.name)); actualOutput != test.output {
+				t.Fatalf("unexpected output, got=%v want=%v", actualOutput, test.output)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package marble
+
+import (
+	"errors"
+	"strings"
+)
+
+// Runtime is the runtime of the Go programs generated by Transpile, it is not meant to be used by hand. Its methods
+// evaluate the nodes of a program with the objects and environments of the interpreter, an error panics until Run
+// recovers it. A transpiled function receives the runtime as rt, e.g. rt.Call(env, token, function, args...).
+type Runtime struct{}
+
+// RuntimeValue is an object of the interpreter.
+type RuntimeValue = object
+
+// RuntimeEnv is an environment of the interpreter.
+type RuntimeEnv = environment
+
+// nativeCode is code compiled to Go by the transpiler, source is the marble code it was compiled from. Transpiled
+// function bodies and default values are native code so that calls are bound and evaluated like interpreted ones.
+type nativeCode struct {
+	source   string
+	evaluate func(env *environment) object
+}
+
+func (n *nativeCode) node()           {}
+func (n *nativeCode) statementNode()  {}
+func (n *nativeCode) expressionNode() {}
+func (n *nativeCode) String() string  { return n.source }
+
+// nativeBlock returns a block evaluating the body in its own environment.
+func nativeBlock(source string, body func(env *environment) object) *blockStatement {
+	return &blockStatement{statements: []statement{&nativeCode{source: source, evaluate: body}}}
+}
+
+// nativeValue returns native code evaluating to the value.
+func nativeValue(source string, value object) *nativeCode {
+	return &nativeCode{source: source, evaluate: func(*environment) object { return value }}
+}
+
+// check panics with an error and returns any other object.
+func check(o object) object {
+	if err, ok := o.(*objError); ok {
+		panic(err)
+	}
+	return o
+}
+
+// Run calls the program in env and returns its output, the result or the error of the program.
+func (rt Runtime) Run(env *RuntimeEnv, program func(rt Runtime, env *RuntimeEnv) RuntimeValue) (output string) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err, ok := recovered.(*objError)
+			if !ok {
+				panic(recovered)
+			}
+			output = err.String()
+		}
+	}()
+	switch result := program(rt, env).(type) {
+	case nil:
+		return ""
+	case *objReturn:
+		return result.value.String()
+	default:
+		return result.String()
+	}
+}
+
+// Returned reports whether the value of a statement returns from the enclosing function.
+func (Runtime) Returned(value RuntimeValue) bool {
+	_, ok := value.(*objReturn)
+	return ok
+}
+
+func (Runtime) Return(value RuntimeValue) RuntimeValue { return &objReturn{value: value} }
+
+func (Runtime) Integer(value int64) RuntimeValue { return newInteger(value) }
+
+func (Runtime) Float(value float64) RuntimeValue { return &objFloat{value: value} }
+
+func (Runtime) Boolean(value bool) RuntimeValue { return evalBoolean(value) }
+
+func (Runtime) String(value string) RuntimeValue { return &objString{value: value} }
+
+func (Runtime) Null() RuntimeValue { return objectNull }
+
+// Regex compiles a regular expression literal, e.g. /a+/i, the parser has checked that it is valid.
+func (Runtime) Regex(token Token) RuntimeValue {
+	end := strings.LastIndexByte(token.Literal, '/')
+	compiled, err := compileRegex(token.Literal[1:end], token.Literal[end+1:])
+	if err != nil {
+		panic(newError(token, "invalid regular expression: %v", err))
+	}
+	return compiled
+}
+
+// spreadValue and namedValue mark the elements of arrays and the arguments of calls that are not plain values.
+type spreadValue struct {
+	array *objArray
+}
+
+func (o *spreadValue) objectType() string { return "SPREAD" }
+func (o *spreadValue) String() string     { return "..." + o.array.String() }
+
+type namedValue struct {
+	name  Token
+	value object
+}
+
+func (o *namedValue) objectType() string { return "NAMED" }
+func (o *namedValue) String() string     { return o.name.Literal + ": " + o.value.String() }
+
+// Spread marks an array whose elements are spread into an array literal or the arguments of a call.
+func (Runtime) Spread(token Token, value RuntimeValue) RuntimeValue {
+	array, ok := value.(*objArray)
+	if !ok {
+		panic(newError(token, "cannot spread %v", value.objectType()))
+	}
+	return &spreadValue{array: array}
+}
+
+// Named marks a named argument of a call.
+func (Runtime) Named(name Token, value RuntimeValue) RuntimeValue {
+	return &namedValue{name: name, value: value}
+}
+
+func (Runtime) Array(elements ...RuntimeValue) RuntimeValue {
+	result := make([]object, 0, len(elements))
+	for i := range elements {
+		if spread, ok := elements[i].(*spreadValue); ok {
+			result = append(result, spread.array.elements...)
+			continue
+		}
+		result = append(result, elements[i])
+	}
+	return &objArray{elements: result}
+}
+
+func (Runtime) Map(keys []string, values ...RuntimeValue) RuntimeValue {
+	m := newMap()
+	for i := range keys {
+		m.set(keys[i], values[i])
+	}
+	return m
+}
+
+func (Runtime) Get(env *RuntimeEnv, name Token) RuntimeValue {
+	return check(evalIdentifier(name, env))
+}
+
+// Declare binds the value to the target of a var or const statement.
+func (Runtime) Declare(env *RuntimeEnv, token Token, value RuntimeValue, target pattern) RuntimeValue {
+	mismatch, err := bindPattern(target, value, env, token.Type == CONSTANT)
+	if err != nil {
+		panic(err)
+	}
+	if mismatch != "" {
+		panic(newError(token, "cannot destructure %v into %v: %v", value.objectType(), target, mismatch))
+	}
+	return nil
+}
+
+func (Runtime) Struct(env *RuntimeEnv, name Token, fields ...Token) RuntimeValue {
+	e := &structStatement{name: &identifier{token: name}, fields: make([]*identifier, len(fields))}
+	for i := range fields {
+		e.fields[i] = &identifier{token: fields[i]}
+	}
+	return check(Eval(e, env))
+}
+
+func (Runtime) Assign(env *RuntimeEnv, target Token, value RuntimeValue) RuntimeValue {
+	switch err := env.assign(target.Literal, value); {
+	case errors.Is(err, errConstant):
+		panic(newError(target, "cannot assign to constant '%v'", target.Literal))
+	case errors.Is(err, errUndeclared):
+		panic(newError(target, "identifier '%v' not found", target.Literal))
+	}
+	return value
+}
+
+// AssignMember assigns the value to a member of left, token is the DOT token of the target.
+func (Runtime) AssignMember(token, member Token, left, value RuntimeValue) RuntimeValue {
+	switch left := left.(type) {
+	case *objStruct:
+		if !left.setField(member.Literal, value) {
+			panic(newError(member, "%v has no field '%v'", left.definition.name, member.Literal))
+		}
+		return value
+	case *objMap:
+		left.set(member.Literal, value)
+		return value
+	}
+	panic(newError(token, "unsupported member assignment: %v", left.objectType()))
+}
+
+func (Runtime) Prefix(operator Token, right RuntimeValue) RuntimeValue {
+	return check(evalPrefixExpression(operator, right))
+}
+
+func (Runtime) Infix(env *RuntimeEnv, operator Token, left, right RuntimeValue) RuntimeValue {
+	return check(evalInfixExpression(operator, left, right, env))
+}
+
+// Coalesce returns left unless it is null, right is only evaluated for null.
+func (Runtime) Coalesce(left RuntimeValue, right func() RuntimeValue) RuntimeValue {
+	if left != objectNull {
+		return left
+	}
+	return right()
+}
+
+// Index indexes left, index is not evaluated for an optional index of null.
+func (Runtime) Index(token Token, left RuntimeValue, index func() RuntimeValue) RuntimeValue {
+	if token.Type == OPTIONAL_LBRACKET && left == objectNull {
+		return objectNull
+	}
+	return check(evalIndexExpression(token, left, index()))
+}
+
+func (Runtime) Member(token, member Token, left RuntimeValue) RuntimeValue {
+	if token.Type == OPTIONAL_DOT && left == objectNull {
+		return objectNull
+	}
+	return check(evalMemberExpression(&memberExpression{token: token, member: &identifier{token: member}}, left))
+}
+
+// Block evaluates the body in its own environment.
+func (Runtime) Block(env *RuntimeEnv, body func(env *RuntimeEnv) RuntimeValue) RuntimeValue {
+	return check(Eval(nativeBlock("", body), env))
+}
+
+// If evaluates the consequence or the optional alternative in their own environment.
+func (Runtime) If(env *RuntimeEnv, condition RuntimeValue, consequence, alternative func(env *RuntimeEnv) RuntimeValue) RuntimeValue {
+	e := &ifExpression{condition: nativeValue("", condition), consequence: nativeBlock("", consequence)}
+	if alternative != nil {
+		e.alternative = nativeBlock("", alternative)
+	}
+	return check(evalIfExpression(e, env))
+}
+
+// Arm returns an arm of a match expression, the body is evaluated in the environment of the bound pattern.
+func (Runtime) Arm(target pattern, body func(env *RuntimeEnv) RuntimeValue) *matchArm {
+	return &matchArm{pattern: target, body: nativeBlock("", body)}
+}
+
+func (Runtime) Match(env *RuntimeEnv, token Token, value RuntimeValue, arms ...*matchArm) RuntimeValue {
+	return check(evalMatchExpression(&matchExpression{token: token, value: nativeValue("", value), arms: arms}, env))
+}
+
+// Parameter declares a parameter of a function, defaultValue is nil for parameters without a default value and
+// source is its marble code.
+func (Runtime) Parameter(target pattern, variadic bool, defaultValue func(env *RuntimeEnv) RuntimeValue, source string) *parameter {
+	param := &parameter{target: target, variadic: variadic}
+	if defaultValue != nil {
+		param.defaultValue = &nativeCode{source: source, evaluate: defaultValue}
+	}
+	return param
+}
+
+// Function creates a closure of env, source is the marble code of the body without its braces.
+func (Runtime) Function(env *RuntimeEnv, name, source string, body func(env *RuntimeEnv) RuntimeValue, parameters ...*parameter) RuntimeValue {
+	return &objFunction{name: name, parameters: parameters, body: nativeBlock(source, body), env: env}
+}
+
+// Method declares the function as a method of the struct named by receiverType.
+func (Runtime) Method(env *RuntimeEnv, receiver, receiverType, name Token, function RuntimeValue) RuntimeValue {
+	definition, ok := check(evalIdentifier(receiverType, env)).(*objStructType)
+	if !ok {
+		panic(newError(receiverType, "'%v' is not a struct", receiverType.Literal))
+	}
+	method := function.(*objFunction)
+	method.name = definition.name + "." + name.Literal
+	definition.setMethod(name.Literal, &structMethod{receiver: receiver.Literal, function: method})
+	return objectNull
+}
+
+// Call applies the function to the arguments, named arguments follow the positional ones.
+func (Runtime) Call(env *RuntimeEnv, token Token, function RuntimeValue, arguments ...RuntimeValue) RuntimeValue {
+	args := make([]object, 0, len(arguments))
+	var named map[string]object
+	for i := range arguments {
+		switch argument := arguments[i].(type) {
+		case *spreadValue:
+			args = append(args, argument.array.elements...)
+		case *namedValue:
+			if named == nil {
+				named = make(map[string]object, len(arguments)-i)
+			}
+			if _, ok := named[argument.name.Literal]; ok {
+				panic(newError(argument.name, "duplicate argument '%v'", argument.name.Literal))
+			}
+			named[argument.name.Literal] = argument.value
+		default:
+			args = append(args, argument)
+		}
+	}
+	return check(applyFunction(token, function, args, named, env))
+}
+
+func (Runtime) WildcardPattern(token Token) pattern { return &wildcardPattern{token: token} }
+
+func (Runtime) BindingPattern(name Token) pattern {
+	return &bindingPattern{name: &identifier{token: name}}
+}
+
+// LiteralPattern matches values equal to the value of the literal, source is its marble code.
+func (Runtime) LiteralPattern(source string, value RuntimeValue) pattern {
+	return &literalPattern{value: nativeValue(source, value)}
+}
+
+// ArrayPattern matches arrays, rest is nil for patterns without a rest element.
+func (Runtime) ArrayPattern(token Token, rest pattern, elements ...pattern) pattern {
+	return &arrayPattern{token: token, elements: elements, rest: rest}
+}
+
+func (Runtime) MapPattern(token Token, keys []Token, values ...pattern) pattern {
+	return &mapPattern{token: token, keys: keys, values: values}
+}
+
+func (Runtime) AlternativePattern(alternatives ...pattern) pattern {
+	return &alternativePattern{alternatives: alternatives}
+}
@@ -0,0 +1,434 @@
+package marble
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// stateVersion is written in the header of a snapshot, loading a snapshot of another version fails.
+const stateVersion = 1
+
+const stateHeader = "marble-state"
+
+// instanceObject is the type of a struct instance in a snapshot, instances report the name of their struct as their type.
+const instanceObject = "INSTANCE"
+
+// snapshot is the JSON encoding of an environment. Environments and objects refer to each other by their index so
+// values shared by several bindings, cycles and closures capturing the environment they are declared in are kept.
+// The first environment is the one the snapshot was taken of.
+type snapshot struct {
+	Environments []snapshotEnvironment `json:"environments"`
+	Objects      []snapshotObject      `json:"objects"`
+}
+
+type snapshotEnvironment struct {
+	Outer    int               `json:"outer"` // -1 for the outermost environment
+	Bindings []snapshotBinding `json:"bindings"`
+}
+
+type snapshotBinding struct {
+	Name     string `json:"name"`
+	Value    int    `json:"value"`
+	Constant bool   `json:"constant,omitempty"`
+}
+
+type snapshotObject struct {
+	Type     string           `json:"type"`
+	Value    string           `json:"value,omitempty"`    // scalars, the name of a built-in function or a struct type
+	Elements []int            `json:"elements,omitempty"` // arrays
+	Keys     []string         `json:"keys,omitempty"`     // maps, struct instances and struct type fields
+	Values   []int            `json:"values,omitempty"`   // maps and struct instances
+	Source   string           `json:"source,omitempty"`   // functions
+	Env      int              `json:"env,omitempty"`      // functions
+	Methods  []snapshotMethod `json:"methods,omitempty"`  // struct types
+	Struct   int              `json:"struct,omitempty"`   // struct instances
+}
+
+type snapshotMethod struct {
+	Name     string `json:"name"`
+	Receiver string `json:"receiver"`
+	Function int    `json:"function"`
+}
+
+// SaveState writes the identifiers declared in the environment, along with the environments captured by its
+// closures, preceded by a version header. Built-in functions are saved by name. Generators, tasks and channels
+// cannot be saved.
+func (e *environment) SaveState(w io.Writer) error {
+	encoder := &stateEncoder{
+		objects:      make(map[object]int),
+		environments: make(map[*environment]int),
+		builtins:     make(map[*objBuiltin]string),
+	}
+	// the operating system functions are looked up by the name LoadOS gives them when the state is loaded
+	loaded := NewEnvironment()
+	loaded.LoadOS(Capabilities{})
+	for name := range loaded.bindings() {
+		if value, ok := e.get(name); ok {
+			if builtin, ok := value.(*objBuiltin); ok {
+				encoder.builtins[builtin] = name
+			}
+		}
+	}
+	for name, builtin := range builtins {
+		encoder.builtins[builtin] = name
+	}
+	if _, err := encoder.environment(e); err != nil {
+		return err
+	}
+	output, err := json.Marshal(encoder.state)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%v %v\n%s\n", stateHeader, stateVersion, output)
+	return err
+}
+
+type stateEncoder struct {
+	state        snapshot
+	objects      map[object]int
+	environments map[*environment]int
+	builtins     map[*objBuiltin]string
+}
+
+func (s *stateEncoder) environment(env *environment) (int, error) {
+	if env == nil {
+		return -1, nil
+	}
+	if index, ok := s.environments[env]; ok {
+		return index, nil
+	}
+	if env.generator != nil {
+		return 0, errors.New("cannot save a closure of a running generator")
+	}
+	index := len(s.state.Environments)
+	s.environments[env] = index
+	s.state.Environments = append(s.state.Environments, snapshotEnvironment{})
+
+	var encoded snapshotEnvironment
+	var err error
+	if encoded.Outer, err = s.environment(env.outer); err != nil {
+		return 0, err
+	}
+	bindings := env.bindings()
+	env.mu.RLock()
+	constants := env.constants
+	env.mu.RUnlock()
+	for _, name := range slices.Sorted(maps.Keys(bindings)) {
+		value := bindings[name]
+		if builtin, ok := value.(*objBuiltin); ok && s.builtins[builtin] == name {
+			continue
+		}
+		binding := snapshotBinding{Name: name, Constant: constants[name]}
+		if binding.Value, err = s.object(value); err != nil {
+			return 0, fmt.Errorf("unable to save '%v': %w", name, err)
+		}
+		encoded.Bindings = append(encoded.Bindings, binding)
+	}
+	s.state.Environments[index] = encoded
+	return index, nil
+}
+
+func (s *stateEncoder) object(o object) (int, error) {
+	if index, ok := s.objects[o]; ok {
+		return index, nil
+	}
+	index := len(s.state.Objects)
+	s.objects[o] = index
+	s.state.Objects = append(s.state.Objects, snapshotObject{})
+
+	encoded := snapshotObject{Type: o.objectType()}
+	var err error
+	switch o := o.(type) {
+	case *objInteger, *objBoolean, *objString, *objRegex:
+		encoded.Value = o.String()
+	case *objFloat:
+		encoded.Value = strconv.FormatFloat(o.value, 'g', -1, 64)
+	case *objNull:
+	case *objArray:
+		encoded.Elements = make([]int, len(o.elements))
+		for i := range o.elements {
+			if encoded.Elements[i], err = s.object(o.elements[i]); err != nil {
+				return 0, err
+			}
+		}
+	case *objMap:
+		var values []object
+		encoded.Keys, values = o.entries()
+		if encoded.Values, err = s.values(values); err != nil {
+			return 0, err
+		}
+	case *objBuiltin:
+		name, ok := s.builtins[o]
+		if !ok {
+			return 0, errors.New("cannot save an unknown built-in function")
+		}
+		encoded.Value = name
+	case *objFunction:
+		encoded.Value, encoded.Source = o.name, o.String()
+		if encoded.Env, err = s.environment(o.env); err != nil {
+			return 0, err
+		}
+	case *objStructType:
+		encoded.Value, encoded.Keys = o.name, slices.Clone(o.fields)
+		methods := o.methodTable()
+		for _, name := range slices.Sorted(maps.Keys(methods)) {
+			method := snapshotMethod{Name: name, Receiver: methods[name].receiver}
+			if method.Function, err = s.object(methods[name].function); err != nil {
+				return 0, err
+			}
+			encoded.Methods = append(encoded.Methods, method)
+		}
+	case *objStruct:
+		encoded.Type, encoded.Value = instanceObject, o.definition.name
+		if encoded.Struct, err = s.object(o.definition); err != nil {
+			return 0, err
+		}
+		encoded.Keys = slices.Clone(o.definition.fields)
+		if encoded.Values, err = s.values(o.fieldValues()); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("cannot save a value of type %v", o.objectType())
+	}
+	s.state.Objects[index] = encoded
+	return index, nil
+}
+
+func (s *stateEncoder) values(values []object) ([]int, error) {
+	encoded := make([]int, len(values))
+	for i := range values {
+		var err error
+		if encoded[i], err = s.object(values[i]); err != nil {
+			return nil, err
+		}
+	}
+	return encoded, nil
+}
+
+// LoadState declares the identifiers of a snapshot written by SaveState in the environment. Built-in functions are
+// looked up by name, including the ones loaded into the environment, e.g. by LoadOS.
+func (e *environment) LoadState(r io.Reader) error {
+	input := bufio.NewReader(r)
+	header, err := input.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	name, version, _ := strings.Cut(strings.TrimSpace(header), " ")
+	if name != stateHeader {
+		return errors.New("not a marble state file")
+	}
+	if version != strconv.Itoa(stateVersion) {
+		return fmt.Errorf("unsupported state version '%v', want %v", version, stateVersion)
+	}
+	var state snapshot
+	if err := json.NewDecoder(input).Decode(&state); err != nil {
+		return fmt.Errorf("invalid state: %w", err)
+	}
+	if len(state.Environments) == 0 {
+		return errors.New("invalid state: no environment")
+	}
+	decoder := &stateDecoder{state: state, target: e}
+	return decoder.decode()
+}
+
+type stateDecoder struct {
+	state        snapshot
+	target       *environment
+	environments []*environment
+	objects      []object
+}
+
+// decode allocates every environment and object before linking them so references may point forward.
+func (s *stateDecoder) decode() error {
+	s.environments = make([]*environment, len(s.state.Environments))
+	s.environments[0] = s.target
+	for i := 1; i < len(s.environments); i++ {
+		s.environments[i] = &environment{tracer: s.target.tracer, random: s.target.random}
+	}
+	for i := 1; i < len(s.environments); i++ {
+		outer := s.state.Environments[i].Outer
+		if outer != -1 {
+			env, err := s.environment(outer)
+			if err != nil {
+				return err
+			}
+			s.environments[i].outer = env
+		}
+	}
+
+	s.objects = make([]object, len(s.state.Objects))
+	for i, encoded := range s.state.Objects {
+		o, err := s.allocate(encoded)
+		if err != nil {
+			return err
+		}
+		s.objects[i] = o
+	}
+	for i, encoded := range s.state.Objects {
+		if err := s.fill(s.objects[i], encoded); err != nil {
+			return err
+		}
+	}
+
+	for i, encoded := range s.state.Environments {
+		for _, binding := range encoded.Bindings {
+			value, err := s.object(binding.Value)
+			if err != nil {
+				return err
+			}
+			if !s.environments[i].declare(binding.Name, value, binding.Constant) {
+				return fmt.Errorf("identifier '%v' already declared", binding.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *stateDecoder) environment(index int) (*environment, error) {
+	if index < 0 || index >= len(s.environments) {
+		return nil, fmt.Errorf("invalid state: unknown environment %v", index)
+	}
+	return s.environments[index], nil
+}
+
+func (s *stateDecoder) object(index int) (object, error) {
+	if index < 0 || index >= len(s.objects) {
+		return nil, fmt.Errorf("invalid state: unknown object %v", index)
+	}
+	return s.objects[index], nil
+}
+
+// allocate creates the object, the objects it refers to are set by fill.
+func (s *stateDecoder) allocate(encoded snapshotObject) (object, error) {
+	switch encoded.Type {
+	case INTEGER_OBJ:
+		value, err := strconv.ParseInt(encoded.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state: %w", err)
+		}
+		return newInteger(value), nil
+	case FLOAT_OBJ:
+		value, err := strconv.ParseFloat(encoded.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state: %w", err)
+		}
+		return &objFloat{value: value}, nil
+	case BOOLEAN_OBJ:
+		return evalBoolean(encoded.Value == "true"), nil
+	case STRING_OBJ:
+		return &objString{value: encoded.Value}, nil
+	case NULL_OBJ:
+		return objectNull, nil
+	case REGEX_OBJ:
+		end := strings.LastIndexByte(encoded.Value, '/')
+		if end < 1 {
+			return nil, fmt.Errorf("invalid state: regular expression %v", encoded.Value)
+		}
+		value, err := compileRegex(encoded.Value[1:end], encoded.Value[end+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid state: %w", err)
+		}
+		return value, nil
+	case ARRAY_OBJ:
+		return &objArray{elements: make([]object, len(encoded.Elements))}, nil
+	case MAP_OBJ:
+		return newMap(), nil
+	case BUILTIN_OBJ:
+		if builtin, ok := builtins[encoded.Value]; ok {
+			return builtin, nil
+		}
+		if builtin, ok := s.target.get(encoded.Value); ok {
+			if _, ok := builtin.(*objBuiltin); ok {
+				return builtin, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown built-in function '%v'", encoded.Value)
+	case FUNCTION_OBJ:
+		function, err := parseFunction(encoded.Source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state: function %v: %w", encoded.Value, err)
+		}
+		return &objFunction{name: encoded.Value, generator: function.generator, parameters: function.parameters, body: function.body, layout: function.layout}, nil
+	case STRUCT_OBJ:
+		return &objStructType{name: encoded.Value, fields: encoded.Keys, methods: make(map[string]*structMethod)}, nil
+	case instanceObject:
+		return &objStruct{fields: make(map[string]object)}, nil
+	}
+	return nil, fmt.Errorf("invalid state: unknown object type '%v'", encoded.Type)
+}
+
+func (s *stateDecoder) fill(o object, encoded snapshotObject) error {
+	var err error
+	switch o := o.(type) {
+	case *objArray:
+		for i := range encoded.Elements {
+			if o.elements[i], err = s.object(encoded.Elements[i]); err != nil {
+				return err
+			}
+		}
+	case *objMap:
+		return s.fillValues(encoded, o.set)
+	case *objFunction:
+		o.env, err = s.environment(encoded.Env)
+	case *objStructType:
+		for _, method := range encoded.Methods {
+			value, err := s.object(method.Function)
+			if err != nil {
+				return err
+			}
+			function, ok := value.(*objFunction)
+			if !ok {
+				return fmt.Errorf("invalid state: method %v is not a function", method.Name)
+			}
+			o.setMethod(method.Name, &structMethod{receiver: method.Receiver, function: function})
+		}
+	case *objStruct:
+		definition, err := s.object(encoded.Struct)
+		if err != nil {
+			return err
+		}
+		if o.definition, _ = definition.(*objStructType); o.definition == nil {
+			return fmt.Errorf("invalid state: %v is not a struct", encoded.Value)
+		}
+		return s.fillValues(encoded, func(key string, value object) { o.fields[key] = value })
+	}
+	return err
+}
+
+func (s *stateDecoder) fillValues(encoded snapshotObject, set func(key string, value object)) error {
+	if len(encoded.Keys) != len(encoded.Values) {
+		return errors.New("invalid state: keys and values differ in length")
+	}
+	for i, key := range encoded.Keys {
+		value, err := s.object(encoded.Values[i])
+		if err != nil {
+			return err
+		}
+		set(key, value)
+	}
+	return nil
+}
+
+// parseFunction parses the source of a saved function, identifiers it captures are looked up by name.
+func parseFunction(source string) (*functionExpression, error) {
+	p := NewParser(NewLexer([]byte(source)))
+	program := p.ParseProgram()
+	if issues := p.Errors(); issues != nil {
+		return nil, errors.New(strings.Join(issues, ", "))
+	}
+	if len(program.statements) == 1 {
+		if statement, ok := program.statements[0].(*expressionStatement); ok {
+			if function, ok := statement.value.(*functionExpression); ok {
+				return function, nil
+			}
+		}
+	}
+	return nil, errors.New("not a function")
+}
@@ -0,0 +1,127 @@
+package marble_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	eval "github.com/o-richard/intepreter/marble"
+)
+
+func TestState(t *testing.T) {
+	tests := []struct {
+		name, saved, restored, output string
+		success                       bool
+	}{
+		{name: "scalars", saved: `var i = 42; const f = 2.5; var s = "marble"; var b = true; var n = {}.missing`, restored: "[i, f, s, b, n]", output: "[42, 2.5, marble, true, null]", success: true},
+		{name: "constant", saved: "const limit = 10", restored: "limit = 11", output: "cannot assign to constant 'limit'"},
+		{name: "arrays and maps", saved: `var list = [1, [2, 3], {"four": 4}]; var alias = list`, restored: `[list, list[2].four, alias == list]`, output: "[[1, [2, 3], {four: 4}], 4, true]", success: true},
+		{name: "cycle", saved: `var node = {"name": "root"}; node.self = node; node.name`, restored: "node.self.self.name", output: "root", success: true},
+		{name: "recursive function", saved: "var fib = func(n) { if (n < 2) { return n; }; fib(n - 1) + fib(n - 2) }", restored: "fib(10)", output: "55", success: true},
+		{name: "nested closures", saved: "var counter = func() { var n = 0; func() { n = n + 1; n } }; var a = counter(); var b = a; a(); var c = counter()", restored: "[a(), b(), c()]", output: "[2, 3, 1]", success: true},
+		{name: "arrow function with defaults", saved: "var scale = 3; var f = ([x, y], z = scale) => x * y * z", restored: "f([1, 2])", output: "6", success: true},
+		{name: "generator function", saved: "var numbers = func(n) { yield n; yield n + 1; }", restored: "collect(numbers(1))", output: "[1, 2]", success: true},
+		{name: "structs and methods", saved: "struct Point { x, y }; func (p Point) sum() { p.x + p.y }; var origin = Point(1, 2)", restored: "[origin, origin.sum(), Point(3, 4).sum()]", output: "[Point{x: 1, y: 2}, 3, 7]", success: true},
+		{name: "regular expressions", saved: `var re = /(a+)b/i`, restored: `[re, re.match("xAAb")]`, output: "[/(a+)b/i, [AAb, AA]]", success: true},
+		{name: "built-in functions", saved: "var size = len; var first = [len]", restored: "[size([1, 2]), first[0]([1])]", output: "[2, 1]", success: true},
+		{name: "operating system functions", saved: "var arguments = args", restored: "arguments()", output: "[]", success: true},
+		{name: "redeclaration", saved: "var x = 1", restored: "var x = 2", output: "identifier 'x' already declared"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := eval.NewParser(eval.NewLexer([]byte(test.saved)))
+			program := p.ParseProgram()
+			if errors := p.Errors(); len(errors) != 0 {
+				t.Fatalf("unexpected errors: %v", errors)
+			}
+			saved := eval.NewEnvironment()
+			saved.LoadOS(eval.Capabilities{})
+			if evaluated := eval.Eval(program, saved); evaluated != nil && strings.HasPrefix(evaluated.String(), "line ") {
+				t.Fatalf("unexpected error: %v", evaluated)
+			}
+			var state bytes.Buffer
+			if err := saved.SaveState(&state); err != nil {
+				t.Fatalf("unable to save state: %v", err)
+			}
+
+			restored := eval.NewEnvironment()
+			restored.LoadOS(eval.Capabilities{})
+			if err := restored.LoadState(&state); err != nil {
+				t.Fatalf("unable to load state: %v", err)
+			}
+			p = eval.NewParser(eval.NewLexer([]byte(test.restored)))
+			program = p.ParseProgram()
+			if errors := p.Errors(); len(errors) != 0 {
+				t.Fatalf("unexpected errors: %v", errors)
+			}
+			evaluated := eval.Eval(program, restored)
+			var actuatlOutput string
+			if evaluated != nil {
+				actuatlOutput = evaluated.String()
+			}
+			if test.success && actuatlOutput != test.output {
+				t.Fatalf("unexpected output, got=%v want=%v", actuatlOutput, test.output)
+			}
+			if !test.success && !strings.Contains(actuatlOutput, test.output) {
+				t.Fatalf("unexpected output, got=%v want=%v", actuatlOutput, test.output)
+			}
+		})
+	}
+}
+
+func TestStateErrors(t *testing.T) {
+	env := eval.NewEnvironment()
+	eval.Eval(eval.NewParser(eval.NewLexer([]byte("var c = channel()"))).ParseProgram(), env)
+	if err := env.SaveState(&bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "unable to save 'c': cannot save a value of type CHANNEL") {
+		t.Fatalf("unexpected error saving a channel: %v", err)
+	}
+
+	tests := []struct {
+		name, state, err string
+	}{
+		{name: "missing header", state: `{"environments": []}`, err: "not a marble state file"},
+		{name: "unsupported version", state: "marble-state 2\n{}", err: "unsupported state version '2'"},
+		{name: "invalid JSON", state: "marble-state 1\n{", err: "invalid state"},
+		{name: "unknown object", state: `marble-state 1` + "\n" + `{"environments": [{"outer": -1, "bindings": [{"name": "x", "value": 3}]}], "objects": []}`, err: "unknown object 3"},
+		{name: "unknown built-in function", state: `marble-state 1` + "\n" + `{"environments": [{"outer": -1, "bindings": [{"name": "x", "value": 0}]}], "objects": [{"type": "BUILTIN", "value": "missing"}]}`, err: "unknown built-in function 'missing'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := eval.NewEnvironment().LoadState(strings.NewReader(test.state))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("unexpected error, got=%v want=%v", err, test.err)
+			}
+		})
+	}
+}
+
+// TestStateTrace checks that functions restored before a tracer is attached are traced when they are called.
+func TestStateTrace(t *testing.T) {
+	saved := eval.NewEnvironment()
+	eval.Eval(eval.NewParser(eval.NewLexer([]byte("var make = func() { func() { 1; 2; } }; var f = make()"))).ParseProgram(), saved)
+	var state bytes.Buffer
+	if err := saved.SaveState(&state); err != nil {
+		t.Fatalf("unable to save state: %v", err)
+	}
+
+	restored := eval.NewEnvironment()
+	if err := restored.LoadState(&state); err != nil {
+		t.Fatalf("unable to load state: %v", err)
+	}
+	program := eval.NewParser(eval.NewLexer([]byte("f()"))).ParseProgram()
+	profiler := eval.NewProfiler(program)
+	restored.Trace(profiler)
+	if evaluated := eval.Eval(program, restored); evaluated.String() != "2" {
+		t.Fatalf("unexpected result, got=%v want=2", evaluated.String())
+	}
+	var report bytes.Buffer
+	if err := profiler.WriteReport(&report); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "anonymous function  1     1"; !strings.Contains(report.String(), expected) {
+		t.Fatalf("unexpected report, got=%q want=%q", report.String(), expected)
+	}
+	if expected := "LINE  COUNT\n1     3\n"; !strings.Contains(report.String(), expected) {
+		t.Fatalf("unexpected report, got=%q want=%q", report.String(), expected)
+	}
+}
@@ -0,0 +1,95 @@
+package marble
+
+import (
+	"time"
+)
+
+// Times are integers counting the milliseconds since the Unix epoch and durations are integers counting
+// milliseconds, both are formatted and parsed in UTC.
+
+func builtinNow(token Token, args ...object) object {
+	if len(args) != 0 {
+		return newError(token, "wrong number of arguments")
+	}
+	return newInteger(time.Now().UnixMilli())
+}
+
+func builtinSleep(token Token, args ...object) object {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	duration, ok := args[0].(*objInteger)
+	if !ok {
+		return newError(token, "invalid argument type: %v", args[0].objectType())
+	}
+	if duration.value < 0 {
+		return newError(token, "invalid negative duration %v", duration.value)
+	}
+	time.Sleep(time.Duration(duration.value) * time.Millisecond)
+	return objectNull
+}
+
+// timeLayout returns the optional layout argument, RFC 3339 by default. Times are formatted with milliseconds and
+// parsed with optional fractional seconds.
+func timeLayout(args []object, fallback string) (string, object) {
+	if maxArgs := 2; len(args) < maxArgs {
+		return fallback, nil
+	}
+	layout, ok := args[1].(*objString)
+	if !ok {
+		return "", args[1]
+	}
+	return layout.value, nil
+}
+
+// builtinTimeFormat formats a time with a Go reference layout, e.g. "2006-01-02 15:04".
+func builtinTimeFormat(token Token, args ...object) object {
+	if maxArgs := 2; len(args) == 0 || len(args) > maxArgs {
+		return newError(token, "wrong number of arguments")
+	}
+	milliseconds, ok := args[0].(*objInteger)
+	if !ok {
+		return newError(token, "invalid argument type: %v", args[0].objectType())
+	}
+	layout, invalid := timeLayout(args, "2006-01-02T15:04:05.000Z07:00")
+	if invalid != nil {
+		return newError(token, "invalid argument type: %v", invalid.objectType())
+	}
+	return &objString{value: time.UnixMilli(milliseconds.value).UTC().Format(layout)}
+}
+
+// builtinTimeParse parses a time with a Go reference layout, times without a zone are in UTC.
+func builtinTimeParse(token Token, args ...object) object {
+	if maxArgs := 2; len(args) == 0 || len(args) > maxArgs {
+		return newError(token, "wrong number of arguments")
+	}
+	value, ok := args[0].(*objString)
+	if !ok {
+		return newError(token, "invalid argument type: %v", args[0].objectType())
+	}
+	layout, invalid := timeLayout(args, time.RFC3339)
+	if invalid != nil {
+		return newError(token, "invalid argument type: %v", invalid.objectType())
+	}
+	parsed, err := time.Parse(layout, value.value)
+	if err != nil {
+		return newError(token, "could not parse '%v' as time: %v", value.value, err)
+	}
+	return newInteger(parsed.UnixMilli())
+}
+
+// builtinDuration parses a duration such as "1h30m" or "250ms" into milliseconds.
+func builtinDuration(token Token, args ...object) object {
+	if len(args) != 1 {
+		return newError(token, "wrong number of arguments")
+	}
+	value, ok := args[0].(*objString)
+	if !ok {
+		return newError(token, "invalid argument type: %v", args[0].objectType())
+	}
+	duration, err := time.ParseDuration(value.value)
+	if err != nil {
+		return newError(token, "could not parse '%v' as duration", value.value)
+	}
+	return newInteger(duration.Milliseconds())
+}
@@ -8,6 +8,7 @@ const (
 	STRING     = "STRING"
 	FLOAT      = "FLOAT"
 	INTEGER    = "INTEGER"
+	REGEX      = "REGEX"
 
 	ASSIGN   = "="
 	ADD      = "+"
@@ -15,6 +16,10 @@ const (
 	MULTIPLY = "*"
 	DIVIDE   = "/"
 	NEGATE   = "!"
+	ARROW    = "=>"
+	PIPE     = "|>"
+	BAR      = "|"
+	COALESCE = "??"
 
 	LT    = "<"
 	GT    = ">"
@@ -25,6 +30,12 @@ const (
 
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	DOT       = "."
+	ELLIPSIS  = "..."
+
+	OPTIONAL_DOT      = "?."
+	OPTIONAL_LBRACKET = "?["
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -35,11 +46,17 @@ const (
 
 	FUNCTION = "FUNCTION"
 	VARIABLE = "VARIABLE"
+	CONSTANT = "CONSTANT"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	NULL     = "NULL"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	STRUCT   = "STRUCT"
+	MATCH    = "MATCH"
+	YIELD    = "YIELD"
+	SPAWN    = "SPAWN"
 )
 
 type TokenType string
@@ -0,0 +1,46 @@
+package marble
+
+// tracer observes the evaluation of a program, e.g. a debugger or a profiler.
+type tracer interface {
+	// beforeStatement is called before each statement is evaluated, returning an error stops the evaluation.
+	beforeStatement(s statement, env *environment) *objError
+	// call is called before the body of a function is evaluated, the returned function is called once the body returns.
+	call(f *frame) func()
+}
+
+// Trace attaches the tracer to the environment, the environments enclosed by it and the functions called from them.
+func (e *environment) Trace(t tracer) {
+	e.tracer = t
+}
+
+// frame is an active function call, it links to the frame of its caller.
+type frame struct {
+	function   string
+	definition Token // first token of the function body
+	token      Token // token of the call site
+	caller     *frame
+}
+
+func newFrame(function *objFunction, token Token, caller *frame) *frame {
+	f := &frame{function: function.name, definition: function.body.token, token: token, caller: caller}
+	if f.function == "" {
+		f.function = "anonymous function"
+	}
+	return f
+}
+
+func statementToken(s statement) Token {
+	switch s := s.(type) {
+	case *varStatement:
+		return s.token
+	case *returnStatement:
+		return s.token
+	case *expressionStatement:
+		return s.token
+	case *structStatement:
+		return s.token
+	case *blockStatement:
+		return s.token
+	}
+	return Token{}
+}
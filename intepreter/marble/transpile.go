@@ -0,0 +1,424 @@
+package marble
+
+import (
+	"fmt"
+	"go/format"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const transpiledMain = `// Code generated by marble. DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/o-richard/intepreter/marble"
+)
+
+func main() {
+	env := marble.NewEnvironment()
+	env.LoadOS(marble.Capabilities{Args: os.Args[1:]})
+	fmt.Println(marble.Runtime{}.Run(env, program))
+}
+
+%v`
+
+// transpiler compiles the nodes of a program to Go code calling the methods of the runtime rt, every expression
+// compiles to a call returning a marble.RuntimeValue and every block to a function of its environment.
+type transpiler struct {
+	returns int // return statements compiled so far, a statement containing one may return from its block
+}
+
+// Transpile compiles the program to a Go main package that runs it like the interpreter and prints its result.
+// Generator functions and spawned calls cannot be transpiled.
+func Transpile(p *program) ([]byte, error) {
+	function, err := TranspileFunction(p, "program")
+	if err != nil {
+		return nil, err
+	}
+	return format.Source([]byte(fmt.Sprintf(transpiledMain, string(function))))
+}
+
+// TranspileFunction compiles the program to a Go function with the name, the function runs the program when it is
+// passed to marble.Runtime{}.Run with an environment.
+func TranspileFunction(p *program, name string) ([]byte, error) {
+	t := &transpiler{}
+	body, err := t.statements(p.statements)
+	if err != nil {
+		return nil, err
+	}
+	return format.Source([]byte(fmt.Sprintf("func %v(rt marble.Runtime, env *marble.RuntimeEnv) marble.RuntimeValue {\n%v}\n", name, body)))
+}
+
+func unsupported(token Token, description string) error {
+	return fmt.Errorf("line %v column %v: cannot transpile %v", token.LineNumber, token.ColNumber, description)
+}
+
+func goToken(token Token) string {
+	return fmt.Sprintf("%#v", token)
+}
+
+// statements compiles the statements of a block to the body of its function, which returns the value of the last
+// statement or the value of a return statement.
+func (t *transpiler) statements(statements []statement) (string, error) {
+	var output strings.Builder
+	for i := range statements {
+		if s, ok := statements[i].(*returnStatement); ok {
+			value, err := t.expression(s.value)
+			if err != nil {
+				return "", err
+			}
+			t.returns++
+			_, _ = output.WriteString("return rt.Return(" + value + ")\n")
+			return output.String(), nil
+		}
+
+		returns := t.returns
+		code, err := t.statement(statements[i])
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case i == len(statements)-1:
+			_, _ = output.WriteString("return " + code + "\n")
+		case t.returns > returns:
+			_, _ = output.WriteString("if result := " + code + "; rt.Returned(result) {\nreturn result\n}\n")
+		default:
+			_, _ = output.WriteString(code + "\n")
+		}
+	}
+	if len(statements) == 0 {
+		_, _ = output.WriteString("return nil\n")
+	}
+	return output.String(), nil
+}
+
+// block compiles the statements to a function of the environment of the block.
+func (t *transpiler) block(statements []statement) (string, error) {
+	body, err := t.statements(statements)
+	if err != nil {
+		return "", err
+	}
+	return "func(env *marble.RuntimeEnv) marble.RuntimeValue {\n" + body + "}", nil
+}
+
+func (t *transpiler) statement(s statement) (string, error) {
+	switch s := s.(type) {
+	case *varStatement:
+		value, err := t.expression(s.value)
+		if err != nil {
+			return "", err
+		}
+		target, err := t.pattern(s.target)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("rt.Declare(env, %v, %v, %v)", goToken(s.token), value, target), nil
+	case *structStatement:
+		fields := make([]string, 0, len(s.fields)+1)
+		fields = append(fields, goToken(s.name.token))
+		for i := range s.fields {
+			fields = append(fields, goToken(s.fields[i].token))
+		}
+		return fmt.Sprintf("rt.Struct(env, %v)", strings.Join(fields, ", ")), nil
+	case *expressionStatement:
+		return t.expression(s.value)
+	case *blockStatement:
+		body, err := t.block(s.statements)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("rt.Block(env, %v)", body), nil
+	}
+	return "", fmt.Errorf("cannot transpile statement %v", s)
+}
+
+func (t *transpiler) expressions(expressions []expression) (string, error) {
+	compiled := make([]string, len(expressions))
+	for i := range expressions {
+		var err error
+		if compiled[i], err = t.expression(expressions[i]); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(compiled, ", "), nil
+}
+
+func (t *transpiler) expression(e expression) (string, error) {
+	switch e := e.(type) {
+	case *identifier:
+		return fmt.Sprintf("rt.Get(env, %v)", goToken(e.token)), nil
+	case *integerLiteral:
+		return fmt.Sprintf("rt.Integer(%v)", e.value), nil
+	case *floatLiteral:
+		if math.IsInf(e.value, 0) || math.IsNaN(e.value) {
+			return "", unsupported(e.token, "the float "+e.String())
+		}
+		return fmt.Sprintf("rt.Float(%v)", strconv.FormatFloat(e.value, 'g', -1, 64)), nil
+	case *booleanLiteral:
+		return fmt.Sprintf("rt.Boolean(%v)", e.value), nil
+	case *nullLiteral:
+		return "rt.Null()", nil
+	case *stringLiteral:
+		return fmt.Sprintf("rt.String(%q)", e.token.Literal), nil
+	case *regexLiteral:
+		return fmt.Sprintf("rt.Regex(%v)", goToken(e.token)), nil
+	case *arrayLiteral:
+		elements, err := t.expressions(e.elements)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("rt.Array(%v)", elements), nil
+	case *spreadExpression:
+		value, err := t.expression(e.value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("rt.Spread(%v, %v)", goToken(e.token), value), nil
+	case *mapLiteral:
+		keys := make([]string, len(e.keys))
+		for i := range e.keys {
+			keys[i] = strconv.Quote(e.keys[i].(*stringLiteral).token.Literal)
+		}
+		values, err := t.expressions(e.values)
+		if err != nil {
+			return "", err
+		}
+		if len(keys) == 0 {
+			return "rt.Map(nil)", nil
+		}
+		return fmt.Sprintf("rt.Map([]string{%v}, %v)", strings.Join(keys, ", "), values), nil
+	case *prefixExpression:
+		right, err := t.expression(e.right)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("rt.Prefix(%v, %v)", goToken(e.operator), right), nil
+	case *infixExpression:
+		left, err := t.expression(e.left)
+		if err != nil {
+			return "", err
+		}
+		right, err := t.expression(e.right)
+		if err != nil {
+			return "", err
+		}
+		if e.operator.Type == COALESCE {
+			return fmt.Sprintf("rt.Coalesce(%v, func() marble.RuntimeValue { return %v })", left, right), nil
+		}
+		return fmt.Sprintf("rt.Infix(env, %v, %v, %v)", goToken(e.operator), left, right), nil
+	case *ifExpression:
+		condition, err := t.expression(e.condition)
+		if err != nil {
+			return "", err
+		}
+		consequence, err := t.block(e.consequence.statements)
+		if err != nil {
+			return "", err
+		}
+		alternative := "nil"
+		if e.alternative != nil {
+			if alternative, err = t.block(e.alternative.statements); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("rt.If(env, %v, %v, %v)", condition, consequence, alternative), nil
+	case *blockExpression:
+		return t.statement(e.block)
+	case *matchExpression:
+		value, err := t.expression(e.value)
+		if err != nil {
+			return "", err
+		}
+		arms := make([]string, len(e.arms))
+		for i, arm := range e.arms {
+			pattern, err := t.pattern(arm.pattern)
+			if err != nil {
+				return "", err
+			}
+			body, err := t.block(arm.body.statements)
+			if err != nil {
+				return "", err
+			}
+			arms[i] = fmt.Sprintf("rt.Arm(%v, %v)", pattern, body)
+		}
+		return fmt.Sprintf("rt.Match(env, %v, %v, %v)", goToken(e.token), value, strings.Join(arms, ", ")), nil
+	case *functionExpression:
+		return t.function(e)
+	case *callExpression:
+		function, err := t.expression(e.function)
+		if err != nil {
+			return "", err
+		}
+		args := make([]string, 0, len(e.arguments)+1)
+		args = append(args, function)
+		for i := range e.arguments {
+			var arg string
+			if named, ok := e.arguments[i].(*namedArgument); ok {
+				value, err := t.expression(named.value)
+				if err != nil {
+					return "", err
+				}
+				arg = fmt.Sprintf("rt.Named(%v, %v)", goToken(named.name.token), value)
+			} else if arg, err = t.expression(e.arguments[i]); err != nil {
+				return "", err
+			}
+			args = append(args, arg)
+		}
+		return fmt.Sprintf("rt.Call(env, %v, %v)", goToken(e.token), strings.Join(args, ", ")), nil
+	case *indexExpression:
+		left, err := t.expression(e.left)
+		if err != nil {
+			return "", err
+		}
+		index, err := t.expression(e.index)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("rt.Index(%v, %v, func() marble.RuntimeValue { return %v })", goToken(e.token), left, index), nil
+	case *memberExpression:
+		left, err := t.expression(e.left)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("rt.Member(%v, %v, %v)", goToken(e.token), goToken(e.member.token), left), nil
+	case *assignExpression:
+		return t.assignment(e)
+	case *yieldExpression:
+		return "", unsupported(e.token, "yield expressions")
+	case *spawnExpression:
+		return "", unsupported(e.token, "spawn expressions")
+	}
+	return "", fmt.Errorf("cannot transpile expression %v", e)
+}
+
+func (t *transpiler) assignment(e *assignExpression) (string, error) {
+	switch target := e.target.(type) {
+	case *identifier:
+		value, err := t.expression(e.value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("rt.Assign(env, %v, %v)", goToken(target.token), value), nil
+	case *memberExpression:
+		left, err := t.expression(target.left)
+		if err != nil {
+			return "", err
+		}
+		value, err := t.expression(e.value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("rt.AssignMember(%v, %v, %v, %v)", goToken(target.token), goToken(target.member.token), left, value), nil
+	}
+	return "", unsupported(e.token, "the assignment target "+e.target.String())
+}
+
+// function compiles a function expression, return statements in its body do not return from the enclosing block.
+func (t *transpiler) function(e *functionExpression) (string, error) {
+	if e.generator {
+		return "", unsupported(e.token, "generator functions")
+	}
+	returns := t.returns
+	defer func() { t.returns = returns }()
+
+	args := make([]string, 0, len(e.parameters)+4)
+	name := ""
+	if e.name != nil && e.receiver == nil {
+		name = e.name.token.Literal
+	}
+	source := make([]string, len(e.body.statements))
+	for i := range e.body.statements {
+		source[i] = e.body.statements[i].String()
+	}
+	body, err := t.block(e.body.statements)
+	if err != nil {
+		return "", err
+	}
+	args = append(args, "env", strconv.Quote(name), strconv.Quote(strings.Join(source, "")), body)
+	for _, param := range e.parameters {
+		target, err := t.pattern(param.target)
+		if err != nil {
+			return "", err
+		}
+		defaultValue, defaultSource := "nil", ""
+		if param.defaultValue != nil {
+			value, err := t.expression(param.defaultValue)
+			if err != nil {
+				return "", err
+			}
+			defaultValue, defaultSource = "func(env *marble.RuntimeEnv) marble.RuntimeValue { return "+value+" }", param.defaultValue.String()
+		}
+		args = append(args, fmt.Sprintf("rt.Parameter(%v, %v, %v, %q)", target, param.variadic, defaultValue, defaultSource))
+	}
+	function := fmt.Sprintf("rt.Function(%v)", strings.Join(args, ", "))
+	if e.receiver != nil {
+		return fmt.Sprintf("rt.Method(env, %v, %v, %v, %v)", goToken(e.receiver.token), goToken(e.receiverType.token), goToken(e.name.token), function), nil
+	}
+	return function, nil
+}
+
+func (t *transpiler) patterns(patterns []pattern) (string, error) {
+	compiled := make([]string, len(patterns))
+	for i := range patterns {
+		var err error
+		if compiled[i], err = t.pattern(patterns[i]); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(compiled, ", "), nil
+}
+
+func (t *transpiler) pattern(p pattern) (string, error) {
+	switch p := p.(type) {
+	case *wildcardPattern:
+		return fmt.Sprintf("rt.WildcardPattern(%v)", goToken(p.token)), nil
+	case *bindingPattern:
+		return fmt.Sprintf("rt.BindingPattern(%v)", goToken(p.name.token)), nil
+	case *literalPattern:
+		value, err := t.expression(p.value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("rt.LiteralPattern(%q, %v)", p.String(), value), nil
+	case *arrayPattern:
+		elements, err := t.patterns(p.elements)
+		if err != nil {
+			return "", err
+		}
+		rest := "nil"
+		if p.rest != nil {
+			if rest, err = t.pattern(p.rest); err != nil {
+				return "", err
+			}
+		}
+		if elements != "" {
+			elements = ", " + elements
+		}
+		return fmt.Sprintf("rt.ArrayPattern(%v, %v%v)", goToken(p.token), rest, elements), nil
+	case *mapPattern:
+		keys := make([]string, len(p.keys))
+		for i := range p.keys {
+			keys[i] = goToken(p.keys[i])
+		}
+		values, err := t.patterns(p.values)
+		if err != nil {
+			return "", err
+		}
+		if values != "" {
+			values = ", " + values
+		}
+		return fmt.Sprintf("rt.MapPattern(%v, []marble.Token{%v}%v)", goToken(p.token), strings.Join(keys, ", "), values), nil
+	case *alternativePattern:
+		alternatives, err := t.patterns(p.alternatives)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("rt.AlternativePattern(%v)", alternatives), nil
+	}
+	return "", fmt.Errorf("cannot transpile pattern %v", p)
+}
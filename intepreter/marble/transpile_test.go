@@ -0,0 +1,143 @@
+package marble_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	eval "github.com/o-richard/intepreter/marble"
+)
+
+// evalOutput evaluates the input and returns what it prints followed by its result, like a transpiled program.
+func evalOutput(t *testing.T, input []byte, loadOS bool) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("unable to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	printed := make(chan string)
+	go func() {
+		output, _ := io.ReadAll(reader)
+		printed <- string(output)
+	}()
+
+	env := eval.NewEnvironment()
+	if loadOS {
+		env.LoadOS(eval.Capabilities{})
+	}
+	evaluated := eval.Eval(eval.NewParser(eval.NewLexer(input)).ParseProgram(), env)
+	os.Stdout = stdout
+	_ = writer.Close()
+	var actuatlOutput string
+	if evaluated != nil {
+		actuatlOutput = evaluated.String()
+	}
+	return <-printed + actuatlOutput + "\n"
+}
+
+// TestTranspile compiles example.marble and the programs of TestEval to one Go program and compares the output of
+// every program with the output of Eval. Programs that cannot be transpiled, e.g. generators, are skipped.
+func TestTranspile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping building a transpiled program in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("skipping without the go command")
+	}
+	module, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatalf("unable to find module: %v", err)
+	}
+
+	type transpiled struct {
+		name, want string
+	}
+	var programs []transpiled
+	var functions, calls strings.Builder
+	add := func(name string, input []byte, loadOS bool) {
+		p := eval.NewParser(eval.NewLexer(input))
+		program := p.ParseProgram()
+		if errors := p.Errors(); len(errors) != 0 {
+			t.Fatalf("unexpected errors in %v: %v", name, errors)
+		}
+		function, err := eval.TranspileFunction(program, fmt.Sprintf("program%v", len(programs)))
+		if err != nil {
+			if !strings.Contains(err.Error(), "cannot transpile") {
+				t.Fatalf("unable to transpile %v: %v", name, err)
+			}
+			t.Logf("skipping %v: %v", name, err)
+			return
+		}
+		_, _ = functions.Write(function)
+		_, _ = fmt.Fprintf(&calls, "\tfmt.Println(%q)\n\tenv%v := marble.NewEnvironment()\n", "=== "+name, len(programs))
+		if loadOS {
+			_, _ = fmt.Fprintf(&calls, "\tenv%v.LoadOS(marble.Capabilities{})\n", len(programs))
+		}
+		_, _ = fmt.Fprintf(&calls, "\tfmt.Println(marble.Runtime{}.Run(env%v, program%v))\n", len(programs), len(programs))
+		programs = append(programs, transpiled{name: name, want: evalOutput(t, input, loadOS)})
+	}
+
+	example, err := os.ReadFile(filepath.Join(module, "example.marble"))
+	if err != nil {
+		t.Fatalf("unable to read example: %v", err)
+	}
+	add("example.marble", example, true)
+	for _, test := range evalTests {
+		add(test.name, []byte(test.input), false)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  fmt.Sprintf("module transpiled\n\ngo 1.23.1\n\nrequire github.com/o-richard/intepreter v0.0.0\n\nreplace github.com/o-richard/intepreter => %v\n", module),
+		"main.go": fmt.Sprintf("package main\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/o-richard/intepreter/marble\"\n)\n\nfunc main() {\n%v}\n\n%v", calls.String(), functions.String()),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("unable to write %v: %v", name, err)
+		}
+	}
+	command := exec.Command("go", "run", ".")
+	command.Dir = dir
+	command.Env = append(os.Environ(), "GOWORK=off", "GOPROXY=off", "GOFLAGS=")
+	output, err := command.CombinedOutput()
+	if err != nil {
+		t.Fatalf("unable to run transpiled programs: %v\n%s", err, output)
+	}
+
+	sections := strings.Split(string(output), "=== ")[1:]
+	if len(sections) != len(programs) {
+		t.Fatalf("unexpected number of outputs, got=%v want=%v", len(sections), len(programs))
+	}
+	for i, program := range programs {
+		t.Run(program.name, func(t *testing.T) {
+			actual := strings.TrimPrefix(sections[i], program.name+"\n")
+			if actual != program.want {
+				t.Fatalf("unexpected output, got=%q want=%q", actual, program.want)
+			}
+		})
+	}
+}
+
+func TestTranspileErrors(t *testing.T) {
+	tests := []struct {
+		name, input, err string
+	}{
+		{name: "generator function", input: "var numbers = func() { yield 1; }", err: "line 1 column 15: cannot transpile generator functions"},
+		{name: "spawn expression", input: "var f = func() { 1 }; spawn f()", err: "line 1 column 23: cannot transpile spawn expressions"},
+		{name: "nested spawn expression", input: "var f = func() { [spawn f()] }", err: "cannot transpile spawn expressions"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := eval.Transpile(eval.NewParser(eval.NewLexer([]byte(test.input))).ParseProgram())
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("unexpected error, got=%v want=%v", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package marble
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ASTNode is a generic view of a parsed node for debugging output and external tools.
+type ASTNode struct {
+	Type     string     `json:"type"`
+	Value    string     `json:"value,omitempty"`
+	Line     int        `json:"line"`
+	Column   int        `json:"column"`
+	Children []*ASTNode `json:"children,omitempty"`
+}
+
+// String renders the node and its children as an indented tree, one node per line.
+func (n *ASTNode) String() string {
+	var output strings.Builder
+	n.write(&output, 0)
+	return output.String()
+}
+
+func (n *ASTNode) write(output *strings.Builder, depth int) {
+	_, _ = output.WriteString(strings.Repeat("  ", depth))
+	_, _ = output.WriteString(n.Type)
+	if n.Value != "" {
+		_, _ = fmt.Fprintf(output, " %q", n.Value)
+	}
+	if n.Line != 0 {
+		_, _ = fmt.Fprintf(output, " %v:%v", n.Line, n.Column)
+	}
+	_ = output.WriteByte('\n')
+	for i := range n.Children {
+		n.Children[i].write(output, depth+1)
+	}
+}
+
+// Tree converts the parsed program into a tree of ASTNode.
+func (p *program) Tree() *ASTNode {
+	return tree(p)
+}
+
+func newASTNode(nodeType string, token Token, value string, children ...*ASTNode) *ASTNode {
+	return &ASTNode{Type: nodeType, Value: value, Line: token.LineNumber, Column: token.ColNumber, Children: children}
+}
+
+func treeList[T node](nodes []T) []*ASTNode {
+	children := make([]*ASTNode, 0, len(nodes))
+	for i := range nodes {
+		children = append(children, tree(nodes[i]))
+	}
+	return children
+}
+
+func tree(n node) *ASTNode {
+	switch n := n.(type) {
+	case *program:
+		return &ASTNode{Type: "Program", Children: treeList(n.statements)}
+	case *varStatement:
+		return newASTNode("VarStatement", n.token, n.token.Literal, tree(n.target), tree(n.value))
+	case *returnStatement:
+		return newASTNode("ReturnStatement", n.token, "", tree(n.value))
+	case *expressionStatement:
+		return newASTNode("ExpressionStatement", n.token, "", tree(n.value))
+	case *structStatement:
+		return newASTNode("StructStatement", n.token, n.name.token.Literal, treeList(n.fields)...)
+	case *blockStatement:
+		return newASTNode("BlockStatement", n.token, "", treeList(n.statements)...)
+	case *identifier:
+		return newASTNode("Identifier", n.token, n.token.Literal)
+	case *integerLiteral:
+		return newASTNode("IntegerLiteral", n.token, n.token.Literal)
+	case *floatLiteral:
+		return newASTNode("FloatLiteral", n.token, n.token.Literal)
+	case *booleanLiteral:
+		return newASTNode("BooleanLiteral", n.token, n.token.Literal)
+	case *stringLiteral:
+		return newASTNode("StringLiteral", n.token, n.token.Literal)
+	case *nullLiteral:
+		return newASTNode("NullLiteral", n.token, "null")
+	case *regexLiteral:
+		return newASTNode("RegexLiteral", n.token, n.token.Literal)
+	case *arrayLiteral:
+		return newASTNode("ArrayLiteral", n.token, "", treeList(n.elements)...)
+	case *mapLiteral:
+		entries := make([]*ASTNode, len(n.keys))
+		for i := range n.keys {
+			entries[i] = &ASTNode{Type: "MapEntry", Children: []*ASTNode{tree(n.keys[i]), tree(n.values[i])}}
+			entries[i].Line, entries[i].Column = entries[i].Children[0].Line, entries[i].Children[0].Column
+		}
+		return newASTNode("MapLiteral", n.token, "", entries...)
+	case *prefixExpression:
+		return newASTNode("PrefixExpression", n.operator, n.operator.Literal, tree(n.right))
+	case *infixExpression:
+		return newASTNode("InfixExpression", n.operator, n.operator.Literal, tree(n.left), tree(n.right))
+	case *ifExpression:
+		result := newASTNode("IfExpression", n.token, "", tree(n.condition), tree(n.consequence))
+		if n.alternative != nil {
+			result.Children = append(result.Children, tree(n.alternative))
+		}
+		return result
+	case *functionExpression:
+		result := newASTNode("FunctionExpression", n.token, "")
+		if n.name != nil {
+			result.Value = n.name.token.Literal
+		}
+		if n.receiver != nil {
+			result.Value = n.receiverType.token.Literal + "." + result.Value
+			result.Children = append(result.Children, newASTNode("Receiver", n.receiver.token, n.receiver.token.Literal))
+		}
+		for i := range n.parameters {
+			result.Children = append(result.Children, treeParameter(n.parameters[i]))
+		}
+		return result.append(tree(n.body))
+	case *spreadExpression:
+		return newASTNode("SpreadExpression", n.token, "", tree(n.value))
+	case *namedArgument:
+		return newASTNode("NamedArgument", n.name.token, n.name.token.Literal, tree(n.value))
+	case *callExpression:
+		return newASTNode("CallExpression", n.token, "", tree(n.function)).append(treeList(n.arguments)...)
+	case *indexExpression:
+		result := newASTNode("IndexExpression", n.token, "", tree(n.left), tree(n.index))
+		if n.token.Type == OPTIONAL_LBRACKET {
+			result.Value = n.token.Literal
+		}
+		return result
+	case *memberExpression:
+		result := newASTNode("MemberExpression", n.token, n.member.token.Literal, tree(n.left))
+		if n.token.Type == OPTIONAL_DOT {
+			result.Value = n.token.Literal + result.Value
+		}
+		return result
+	case *assignExpression:
+		return newASTNode("AssignExpression", n.token, "", tree(n.target), tree(n.value))
+	case *matchExpression:
+		result := newASTNode("MatchExpression", n.token, "", tree(n.value))
+		for i := range n.arms {
+			arm := &ASTNode{Type: "MatchArm", Children: []*ASTNode{tree(n.arms[i].pattern), tree(n.arms[i].body)}}
+			arm.Line, arm.Column = arm.Children[0].Line, arm.Children[0].Column
+			result.Children = append(result.Children, arm)
+		}
+		return result
+	case *literalPattern:
+		value := tree(n.value)
+		return &ASTNode{Type: "LiteralPattern", Line: value.Line, Column: value.Column, Children: []*ASTNode{value}}
+	case *wildcardPattern:
+		return newASTNode("WildcardPattern", n.token, "")
+	case *bindingPattern:
+		return newASTNode("BindingPattern", n.name.token, n.name.token.Literal)
+	case *arrayPattern:
+		result := newASTNode("ArrayPattern", n.token, "", treeList(n.elements)...)
+		if n.rest != nil {
+			rest := tree(n.rest)
+			result.Children = append(result.Children, &ASTNode{Type: "RestPattern", Line: rest.Line, Column: rest.Column, Children: []*ASTNode{rest}})
+		}
+		return result
+	case *mapPattern:
+		result := newASTNode("MapPattern", n.token, "")
+		for i := range n.keys {
+			result.Children = append(result.Children, newASTNode("MapPatternEntry", n.keys[i], n.keys[i].Literal, tree(n.values[i])))
+		}
+		return result
+	case *alternativePattern:
+		alternatives := treeList(n.alternatives)
+		return &ASTNode{Type: "AlternativePattern", Line: alternatives[0].Line, Column: alternatives[0].Column, Children: alternatives}
+	case *yieldExpression:
+		result := newASTNode("YieldExpression", n.token, "", tree(n.value))
+		if n.delegate {
+			result.Value = "..."
+		}
+		return result
+	case *spawnExpression:
+		return newASTNode("SpawnExpression", n.token, "", tree(n.call))
+	case *blockExpression:
+		return newASTNode("BlockExpression", n.token, "", tree(n.block))
+	}
+	return &ASTNode{Type: fmt.Sprintf("%T", n)}
+}
+
+func treeParameter(p *parameter) *ASTNode {
+	target := tree(p.target)
+	result := &ASTNode{Type: "Parameter", Line: target.Line, Column: target.Column, Children: []*ASTNode{target}}
+	if p.variadic {
+		result.Value = "..."
+	}
+	if p.defaultValue != nil {
+		result.Children = append(result.Children, tree(p.defaultValue))
+	}
+	return result
+}
+
+func (n *ASTNode) append(children ...*ASTNode) *ASTNode {
+	n.Children = append(n.Children, children...)
+	return n
+}
@@ -0,0 +1,47 @@
+package marble_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	eval "github.com/o-richard/intepreter/marble"
+)
+
+func TestTree(t *testing.T) {
+	tests := []struct {
+		name, input, output string
+	}{
+		{name: "var statement", input: "var x = -1 + 2;", output: "Program\n  VarStatement \"var\" 1:1\n    BindingPattern \"x\" 1:5\n    InfixExpression \"+\" 1:12\n      PrefixExpression \"-\" 1:9\n        IntegerLiteral \"1\" 1:10\n      IntegerLiteral \"2\" 1:14\n"},
+		{name: "arrow function", input: "var add = (x, ...y) => x;", output: "Program\n  VarStatement \"var\" 1:1\n    BindingPattern \"add\" 1:5\n    FunctionExpression \"add\" 1:11\n      Parameter 1:12\n        BindingPattern \"x\" 1:12\n      Parameter \"...\" 1:18\n        BindingPattern \"y\" 1:18\n      BlockStatement 1:24\n        ExpressionStatement 1:24\n          Identifier \"x\" 1:24\n"},
+		{name: "match expression", input: "match (x) {\n  [a, _] | 1 => a\n}", output: "Program\n  ExpressionStatement 1:1\n    MatchExpression 1:1\n      Identifier \"x\" 1:8\n      MatchArm 2:3\n        AlternativePattern 2:3\n          ArrayPattern 2:3\n            BindingPattern \"a\" 2:4\n            WildcardPattern 2:7\n          LiteralPattern 2:12\n            IntegerLiteral \"1\" 2:12\n        BlockStatement 2:17\n          ExpressionStatement 2:17\n            Identifier \"a\" 2:17\n"},
+		{name: "map literal and member access", input: `{name: "marble"}.name`, output: "Program\n  ExpressionStatement 1:1\n    MemberExpression \"name\" 1:17\n      MapLiteral 1:1\n        MapEntry 1:2\n          StringLiteral \"name\" 1:2\n          StringLiteral \"marble\" 1:8\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := eval.NewParser(eval.NewLexer([]byte(test.input)))
+			program := p.ParseProgram()
+			if issues := p.Errors(); len(issues) != 0 {
+				t.Fatalf("unexpected errors: %v", issues)
+			}
+			if actualOutput := program.Tree().String(); actualOutput != test.output {
+				t.Fatalf("unexpected output, got=%q want=%q", actualOutput, test.output)
+			}
+		})
+	}
+}
+
+func TestTreeJSON(t *testing.T) {
+	p := eval.NewParser(eval.NewLexer([]byte("f(1);")))
+	program := p.ParseProgram()
+	if issues := p.Errors(); len(issues) != 0 {
+		t.Fatalf("unexpected errors: %v", issues)
+	}
+	output, err := json.Marshal(program.Tree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"Program","line":0,"column":0,"children":[{"type":"ExpressionStatement","line":1,"column":1,"children":[{"type":"CallExpression","line":1,"column":2,"children":[{"type":"Identifier","value":"f","line":1,"column":1},{"type":"IntegerLiteral","value":"1","line":1,"column":3}]}]}]}`
+	if string(output) != expected {
+		t.Fatalf("unexpected output, got=%v want=%v", string(output), expected)
+	}
+}
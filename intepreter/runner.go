@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/o-richard/intepreter/marble"
+)
+
+// runTests runs the test_* functions of the .marble files under the directories, it returns the exit code.
+func runTests(dirs []string) int {
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	var passed, failed int
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || filepath.Ext(path) != ".marble" {
+				return err
+			}
+			input, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			p := marble.NewParser(marble.NewLexer(input))
+			program := p.ParseProgram()
+			if errors := p.Errors(); errors != nil {
+				fmt.Printf("--- FAIL: %v\n    parsing errors, %v\n", path, errors)
+				failed++
+				return nil
+			}
+			program = marble.Optimize(program)
+			for _, name := range marble.TestFunctions(program) {
+				if err := marble.RunTest(program, name); err != nil {
+					fmt.Printf("--- FAIL: %v (%v)\n    %v\n", name, path, err)
+					failed++
+					continue
+				}
+				fmt.Printf("--- PASS: %v (%v)\n", name, path)
+				passed++
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Println("unable to run tests, ", err)
+			return 1
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("FAIL: %v passed, %v failed\n", passed, failed)
+		return 1
+	}
+	fmt.Printf("ok: %v passed\n", passed)
+	return 0
+}